@@ -2,6 +2,8 @@ package config
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 
 	"github.com/charmbracelet/lipgloss"
 )
@@ -11,13 +13,20 @@ type Config struct {
 	Theme            Theme
 	ThemePreset      ThemePreset
 	HighContrast     bool
+	BlameHeatmap     bool
 	DiffMode         DiffMode
 	ShowLineNo       bool
 	TabSize          int
+	ContextLines     int
+	TemplateDir      string
+	RedactSecrets    bool
+	RedactPatterns   []string
 	IgnoreWhitespace bool
 	IgnorePatterns   []string
 	Language         string
 	TokenPatterns    map[string]string
+	Spacing          SpacingOptions
+	Keybindings      Keybindings
 }
 
 // ThemePreset describes a named theme configuration.
@@ -51,6 +60,9 @@ type Theme struct {
 	TitleFg      lipgloss.Color
 	TitleBg      lipgloss.Color
 	HelpFg       lipgloss.Color
+	// Background is the page colour of exports; the TUI keeps the
+	// terminal's own.
+	Background lipgloss.Color
 }
 
 // DiffMode specifies how differences should be displayed
@@ -71,13 +83,29 @@ func DefaultConfig() *Config {
 		DiffMode:         SideBySide,
 		ShowLineNo:       true,
 		TabSize:          4,
+		ContextLines:     3,
+		TemplateDir:      DefaultTemplateDir(),
+		RedactSecrets:    true,
+		RedactPatterns:   []string{},
 		IgnoreWhitespace: false,
 		IgnorePatterns:   []string{},
 		Language:         "",
 		TokenPatterns:    map[string]string{},
+		Spacing:          DefaultSpacing(),
+		Keybindings:      Keybindings{},
 	}
 }
 
+// DefaultTemplateDir returns the directory searched for export templates,
+// gdiff/templates under the user configuration directory.
+func DefaultTemplateDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "gdiff", "templates")
+}
+
 // DefaultTheme returns the default color theme
 func DefaultTheme() Theme {
 	return Theme{
@@ -91,6 +119,7 @@ func DefaultTheme() Theme {
 		TitleFg:      lipgloss.Color("#FFFFFF"),
 		TitleBg:      lipgloss.Color("#5F5FAF"),
 		HelpFg:       lipgloss.Color("#888888"),
+		Background:   lipgloss.Color("#0F111A"),
 	}
 }
 
@@ -110,6 +139,7 @@ func ThemeForPreset(preset ThemePreset, highContrast bool) Theme {
 			TitleFg:      lipgloss.Color("#EEE8D5"),
 			TitleBg:      lipgloss.Color("#586E75"),
 			HelpFg:       lipgloss.Color("#93A1A1"),
+			Background:   lipgloss.Color("#002B36"),
 		}, highContrast)
 	case PresetDracula:
 		return applyContrast(Theme{
@@ -123,6 +153,7 @@ func ThemeForPreset(preset ThemePreset, highContrast bool) Theme {
 			TitleFg:      lipgloss.Color("#F8F8F2"),
 			TitleBg:      lipgloss.Color("#6272A4"),
 			HelpFg:       lipgloss.Color("#BD93F9"),
+			Background:   lipgloss.Color("#282A36"),
 		}, highContrast)
 	default:
 		return applyContrast(DefaultTheme(), highContrast)
@@ -163,6 +194,14 @@ func DefaultKeybindings() Keybindings {
 		"go_line":             {"L"},
 		"prev_branch":         {"["},
 		"next_branch":         {"]"},
+		"ref_picker":          {"R"},
+		"open_blame_commit":   {"enter"},
+		"line_history":        {"l"},
+		"prev_file":           {"{"},
+		"next_file":           {"}"},
+		"toggle_submodule":    {"M"},
+		"visual_select":       {"V"},
+		"copy_hunk":           {"Y"},
 	}
 }
 
@@ -194,6 +233,7 @@ func applyContrast(theme Theme, highContrast bool) Theme {
 		TitleFg:      lipgloss.Color(adjustBrightness(string(theme.TitleFg), 0.2)),
 		TitleBg:      lipgloss.Color(adjustBrightness(string(theme.TitleBg), 0.2)),
 		HelpFg:       lipgloss.Color(adjustBrightness(string(theme.HelpFg), 0.2)),
+		Background:   theme.Background,
 	}
 }
 
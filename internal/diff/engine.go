@@ -2,7 +2,6 @@ package diff
 
 import (
 	"bufio"
-	"fmt"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -43,6 +42,10 @@ type DiffResult struct {
 	File2Name  string
 	File1Lines []string
 	File2Lines []string
+	File1NoEOL bool      // file 1 does not end with a newline
+	File2NoEOL bool      // file 2 does not end with a newline
+	Binary     bool      // lines are placeholders standing in for binary content
+	FuncName   *FuncName // finds hunk header context; nil when unknown
 }
 
 // Engine handles diff operations
@@ -114,17 +117,19 @@ func NewEngine(options EngineOptions) *Engine {
 
 // DiffFiles compares two files and returns the differences
 func (e *Engine) DiffFiles(file1, file2 string) (*DiffResult, error) {
-	lines1, err := readFileLines(file1)
+	lines1, noEOL1, err := readFileLines(file1)
 	if err != nil {
 		return nil, err
 	}
 
-	lines2, err := readFileLines(file2)
+	lines2, noEOL2, err := readFileLines(file2)
 	if err != nil {
 		return nil, err
 	}
 
-	return e.DiffLines(lines1, lines2, file1, file2), nil
+	result := e.DiffLines(lines1, lines2, file1, file2)
+	result.File1NoEOL, result.File2NoEOL = noEOL1, noEOL2
+	return result, nil
 }
 
 // DiffLines compares two slices of lines
@@ -391,11 +396,12 @@ func compileIgnorePatterns(patterns []string) []*regexp.Regexp {
 	return compiled
 }
 
-// readFileLines reads a file and returns its lines
-func readFileLines(filename string) ([]string, error) {
+// readFileLines reads a file and returns its lines, and whether the last
+// line lacks a trailing newline
+func readFileLines(filename string) ([]string, bool, error) {
 	file, err := os.Open(filename)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 	defer file.Close()
 
@@ -406,10 +412,19 @@ func readFileLines(filename string) ([]string, error) {
 	}
 
 	if err := scanner.Err(); err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
-	return lines, nil
+	// The scanner hides whether the final line was terminated.
+	last := make([]byte, 1)
+	info, err := file.Stat()
+	if err != nil || info.Size() == 0 {
+		return lines, false, err
+	}
+	if _, err := file.ReadAt(last, info.Size()-1); err != nil {
+		return nil, false, err
+	}
+	return lines, last[0] != '\n', nil
 }
 
 // max returns the maximum of two integers
@@ -437,8 +452,8 @@ func (r *DiffResult) GetStats() (added, removed, unchanged int) {
 
 // HasChanges returns true if there are any differences
 func (r *DiffResult) HasChanges() bool {
-	for _, line := range r.Lines {
-		if line.Type != Equal {
+	for i := range r.Lines {
+		if r.LineChanged(i) {
 			return true
 		}
 	}
@@ -0,0 +1,94 @@
+package diff
+
+import (
+	"regexp"
+	"strings"
+)
+
+// maxFuncNameLen matches git, which cuts hunk header context at 80 bytes.
+const maxFuncNameLen = 80
+
+// FuncName picks out the lines that start a function, in the manner of git's
+// xfuncname: patterns are tried in order, a pattern prefixed with "!" rejects
+// the lines it matches, and the first capture group (or the whole line when
+// there is none) becomes the hunk header text.
+type FuncName struct {
+	patterns []funcPattern
+}
+
+type funcPattern struct {
+	re     *regexp.Regexp
+	negate bool
+}
+
+// DefaultFuncName is git's built-in rule: any line starting with a letter,
+// underscore or dollar sign.
+var DefaultFuncName = MustParseFuncName(`^[[:alpha:]$_].*$`)
+
+// ParseFuncName compiles an xfuncname value, one pattern per line.
+func ParseFuncName(spec string) (*FuncName, error) {
+	f := &FuncName{}
+	for _, line := range strings.Split(spec, "\n") {
+		if line == "" {
+			continue
+		}
+		negate := strings.HasPrefix(line, "!")
+		re, err := regexp.Compile(strings.TrimPrefix(line, "!"))
+		if err != nil {
+			return nil, err
+		}
+		f.patterns = append(f.patterns, funcPattern{re: re, negate: negate})
+	}
+	return f, nil
+}
+
+// MustParseFuncName is like ParseFuncName but panics on an invalid pattern.
+func MustParseFuncName(spec string) *FuncName {
+	f, err := ParseFuncName(spec)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+// Match reports whether line starts a function and returns the header text.
+func (f *FuncName) Match(line string) (string, bool) {
+	for _, p := range f.patterns {
+		match := p.re.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		if p.negate {
+			return "", false
+		}
+
+		text := match[0]
+		if len(match) > 1 && match[1] != "" {
+			text = match[1]
+		}
+		text = strings.TrimRight(text, " \t\r")
+		if len(text) > maxFuncNameLen {
+			text = text[:maxFuncNameLen]
+		}
+		return text, true
+	}
+	return "", false
+}
+
+// Context returns the header text of the nearest function line above
+// lines[index] on the old side, as git shows after a hunk's @@ range. A nil
+// FuncName yields no context.
+func (f *FuncName) Context(lines []DiffLine, index int) string {
+	if f == nil {
+		return ""
+	}
+	for i := min(index, len(lines)) - 1; i >= 0; i-- {
+		if lines[i].LineNo1 == 0 {
+			continue
+		}
+		if text, ok := f.Match(lines[i].Content); ok {
+			return text
+		}
+	}
+	return ""
+}
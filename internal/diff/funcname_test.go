@@ -0,0 +1,84 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFuncNameMatch(t *testing.T) {
+	goFunc := `^func (.*)$`
+	tests := []struct {
+		name  string
+		spec  string
+		line  string
+		text  string
+		match bool
+	}{
+		{name: "default rule matches a letter", spec: `^[[:alpha:]$_].*$`, line: "int main(void)", text: "int main(void)", match: true},
+		{name: "default rule skips indented lines", spec: `^[[:alpha:]$_].*$`, line: "\treturn 0;", match: false},
+		{name: "capture group becomes the text", spec: goFunc, line: "func Parse(s string) error {", text: "Parse(s string) error {", match: true},
+		{name: "trailing whitespace is trimmed", spec: `^(def .*)$`, line: "def run(self):  \t", text: "def run(self):", match: true},
+		{name: "negated pattern rejects", spec: "!^func Test\n" + goFunc, line: "func TestParse(t *testing.T) {", match: false},
+		{name: "negated pattern lets others through", spec: "!^func Test\n" + goFunc, line: "func Parse() {", text: "Parse() {", match: true},
+		{name: "first matching pattern wins", spec: "^(class .*)$\n^(.*)$", line: "class Foo:", text: "class Foo:", match: true},
+		{name: "long text is cut at 80 bytes", spec: `^(.*)$`, line: strings.Repeat("x", 100), text: strings.Repeat("x", 80), match: true},
+		{name: "no patterns", spec: "", line: "anything", match: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ParseFuncName(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			text, ok := f.Match(tt.line)
+			if ok != tt.match || text != tt.text {
+				t.Errorf("Match(%q) = %q, %v; want %q, %v", tt.line, text, ok, tt.text, tt.match)
+			}
+		})
+	}
+}
+
+func TestParseFuncNameInvalid(t *testing.T) {
+	if _, err := ParseFuncName("^ok$\n!(unclosed"); err == nil {
+		t.Error("ParseFuncName accepted an invalid pattern")
+	}
+}
+
+func TestFuncNameContext(t *testing.T) {
+	lines := []DiffLine{
+		{Type: Equal, Content: "package main", LineNo1: 1, LineNo2: 1},
+		{Type: Equal, Content: "func a() {", LineNo1: 2, LineNo2: 2},
+		{Type: Equal, Content: "\tx()", LineNo1: 3, LineNo2: 3},
+		{Type: Added, Content: "func added() {", LineNo2: 4},
+		{Type: Removed, Content: "func removed() {", LineNo1: 4},
+		{Type: Equal, Content: "\ty()", LineNo1: 5, LineNo2: 5},
+	}
+	f := MustParseFuncName(`^func (\w+)`)
+
+	tests := []struct {
+		name  string
+		index int
+		text  string
+	}{
+		{name: "nothing above the first line", index: 0, text: ""},
+		{name: "function line itself is not its own context", index: 1, text: ""},
+		{name: "nearest function above", index: 3, text: "a"},
+		{name: "added lines are skipped", index: 4, text: "a"},
+		{name: "removed lines count", index: 5, text: "removed"},
+		{name: "index past the end", index: 10, text: "removed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := f.Context(lines, tt.index); got != tt.text {
+				t.Errorf("Context(%d) = %q, want %q", tt.index, got, tt.text)
+			}
+		})
+	}
+
+	var none *FuncName
+	if got := none.Context(lines, 5); got != "" {
+		t.Errorf("nil FuncName Context() = %q, want none", got)
+	}
+}
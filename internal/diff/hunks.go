@@ -0,0 +1,116 @@
+package diff
+
+import "fmt"
+
+// DefaultContext is the number of unchanged lines kept around each change,
+// as in diff -u.
+const DefaultContext = 3
+
+// Hunk is a run of changes together with its surrounding context lines.
+type Hunk struct {
+	Start    int // index of the first line in DiffResult.Lines
+	End      int // index one past the last line
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+}
+
+// Hunks groups the changed lines into hunks with up to context unchanged
+// lines on either side. Changes closer than twice the context share a hunk.
+// Hunk starts come from the line numbers, so they stay right for a result
+// holding only part of a diff.
+func (r *DiffResult) Hunks(context int) []Hunk {
+	context = max(context, 0)
+
+	var hunks []Hunk
+	for i := range r.Lines {
+		if !r.LineChanged(i) {
+			continue
+		}
+		start := max(0, i-context)
+		end := min(len(r.Lines), i+context+1)
+		if n := len(hunks); n > 0 && start <= hunks[n-1].End {
+			hunks[n-1].End = end
+			continue
+		}
+		hunks = append(hunks, Hunk{Start: start, End: end})
+	}
+
+	// A side with no lines in a hunk is numbered by the line before it.
+	oldBefore, newBefore, next := 0, 0, 0
+	for h := range hunks {
+		for ; next < hunks[h].Start; next++ {
+			oldBefore, newBefore = r.advance(next, oldBefore, newBefore)
+		}
+		hunk := &hunks[h]
+		hunk.OldStart, hunk.NewStart = oldBefore, newBefore
+		for i := hunk.Start; i < hunk.End; i++ {
+			if no := r.Lines[i].LineNo1; no > 0 {
+				if hunk.OldLines == 0 {
+					hunk.OldStart = no
+				}
+				hunk.OldLines++
+			}
+			if no := r.Lines[i].LineNo2; no > 0 {
+				if hunk.NewLines == 0 {
+					hunk.NewStart = no
+				}
+				hunk.NewLines++
+			}
+		}
+	}
+	return hunks
+}
+
+// advance returns the last old and new line numbers seen once Lines[i] has
+// been passed.
+func (r *DiffResult) advance(i, oldLast, newLast int) (int, int) {
+	if no := r.Lines[i].LineNo1; no > 0 {
+		oldLast = no
+	}
+	if no := r.Lines[i].LineNo2; no > 0 {
+		newLast = no
+	}
+	return oldLast, newLast
+}
+
+// LineChanged reports whether Lines[i] differs between the files. Besides
+// added and removed lines this covers a shared last line where only one
+// file ends without a newline.
+func (r *DiffResult) LineChanged(i int) bool {
+	return r.Lines[i].Type != Equal || r.EOFChanged(i)
+}
+
+// EOFChanged reports whether Lines[i] is shared by both files but ends
+// without a newline in only one of them: the last line of a file that lacks
+// its final newline, unless the other file ends on it the same way.
+func (r *DiffResult) EOFChanged(i int) bool {
+	line := r.Lines[i]
+	oldOpen := line.LineNo1 == len(r.File1Lines) && r.File1NoEOL
+	newOpen := line.LineNo2 == len(r.File2Lines) && r.File2NoEOL
+	return line.Type == Equal && oldOpen != newOpen
+}
+
+// HunkHeader formats the @@ line of a hunk, followed by the enclosing
+// function when FuncName finds one.
+func (r *DiffResult) HunkHeader(h Hunk) string {
+	header := fmt.Sprintf("@@ -%s +%s @@", hunkRange(h.OldStart, h.OldLines), hunkRange(h.NewStart, h.NewLines))
+	if fn := r.FuncName.Context(r.Lines, h.Start); fn != "" {
+		header += " " + fn
+	}
+	return header
+}
+
+func hunkRange(start, count int) string {
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+// MissingFinalNewline reports whether non-empty content lacks a trailing
+// newline.
+func MissingFinalNewline(data []byte) bool {
+	return len(data) > 0 && data[len(data)-1] != '\n'
+}
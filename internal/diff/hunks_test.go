@@ -0,0 +1,124 @@
+package diff_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/cj3636/gdiff/internal/diff"
+	"github.com/cj3636/gdiff/internal/difftest"
+)
+
+func TestHunks(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		context  int
+		headers  []string
+	}{
+		{name: "identical", old: "a\nb\n", new: "a\nb\n", context: 3},
+		{name: "change in the middle", old: difftest.Numbered(10), new: difftest.Numbered(10, 5), context: 3, headers: []string{"@@ -2,7 +2,7 @@"}},
+		{name: "distant changes stay apart", old: difftest.Numbered(20), new: difftest.Numbered(20, 2, 18), context: 3,
+			headers: []string{"@@ -1,5 +1,5 @@", "@@ -15,6 +15,6 @@"}},
+		{name: "changes within twice the context merge", old: difftest.Numbered(20), new: difftest.Numbered(20, 5, 11), context: 3,
+			headers: []string{"@@ -2,13 +2,13 @@"}},
+		{name: "no context", old: difftest.Numbered(10), new: difftest.Numbered(10, 5, 7), context: 0,
+			headers: []string{"@@ -5 +5 @@", "@@ -7 +7 @@"}},
+		{name: "negative context counts as none", old: difftest.Numbered(10), new: difftest.Numbered(10, 5), context: -1,
+			headers: []string{"@@ -5 +5 @@"}},
+		{name: "new file", old: "", new: "x\ny\n", context: 3, headers: []string{"@@ -0,0 +1,2 @@"}},
+		{name: "deleted file", old: "x\ny\n", new: "", context: 3, headers: []string{"@@ -1,2 +0,0 @@"}},
+		{name: "pure insertion is numbered by the line before", old: "a\nb\n", new: "a\nnew\nb\n", context: 0,
+			headers: []string{"@@ -1,0 +2 @@"}},
+		{name: "pure deletion is numbered by the line before", old: "a\ngone\nb\n", new: "a\nb\n", context: 0,
+			headers: []string{"@@ -2 +1,0 @@"}},
+		{name: "final newline removed", old: "a\nb\n", new: "a\nb", context: 3, headers: []string{"@@ -1,2 +1,2 @@"}},
+		{name: "lines added after a missing newline", old: "a\nb", new: "a\nb\nc\n", context: 0,
+			headers: []string{"@@ -2 +2,2 @@"}},
+		{name: "both lack a final newline", old: "a\nb", new: "a\nb", context: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := difftest.Texts(tt.old, tt.new)
+			var headers []string
+			for _, h := range result.Hunks(tt.context) {
+				headers = append(headers, result.HunkHeader(h))
+			}
+			if strings.Join(headers, "\n") != strings.Join(tt.headers, "\n") {
+				t.Errorf("hunk headers = %q, want %q", headers, tt.headers)
+			}
+		})
+	}
+}
+
+func TestHunksOfPartialResult(t *testing.T) {
+	result := difftest.Texts(difftest.Numbered(20), difftest.Numbered(20, 15))
+	result.Lines = result.Lines[10:]
+
+	hunks := result.Hunks(2)
+	if len(hunks) != 1 {
+		t.Fatalf("got %d hunks, want 1", len(hunks))
+	}
+	if got, want := result.HunkHeader(hunks[0]), "@@ -13,5 +13,5 @@"; got != want {
+		t.Errorf("HunkHeader() = %q, want %q", got, want)
+	}
+	if hunks[0].Start != 2 || hunks[0].End != 8 {
+		t.Errorf("hunk spans Lines[%d:%d], want [2:8]", hunks[0].Start, hunks[0].End)
+	}
+}
+
+func TestHunkHeaderFuncName(t *testing.T) {
+	old := "package main\n\nfunc a() {\n\tone()\n\ttwo()\n\tthree()\n\tfour()\n\tfive()\n}\n"
+	new := strings.Replace(old, "four", "FOUR", 1)
+
+	tests := []struct {
+		name     string
+		funcName *diff.FuncName
+		header   string
+	}{
+		{name: "no rule", header: "@@ -4,6 +4,6 @@"},
+		{name: "default rule", funcName: diff.DefaultFuncName, header: "@@ -4,6 +4,6 @@ func a() {"},
+		{name: "custom rule", funcName: diff.MustParseFuncName(`^func (\w+)`), header: "@@ -4,6 +4,6 @@ a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := difftest.Texts(old, new)
+			result.FuncName = tt.funcName
+			if got := result.HunkHeader(result.Hunks(3)[0]); got != tt.header {
+				t.Errorf("HunkHeader() = %q, want %q", got, tt.header)
+			}
+		})
+	}
+}
+
+func TestEOFChanged(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		changed  []int // Lines indexes that only differ in their newline
+	}{
+		{name: "both end with a newline", old: "a\nb\n", new: "a\nb\n"},
+		{name: "both lack one", old: "a\nb", new: "a\nb"},
+		{name: "new file drops it", old: "a\nb\n", new: "a\nb", changed: []int{1}},
+		{name: "new file adds it", old: "a\nb", new: "a\nb\n", changed: []int{1}},
+		{name: "old last line continues in the new file", old: "a\nb", new: "a\nb\nc\n", changed: []int{1}},
+		{name: "new last line was followed by more", old: "a\nb\nc\n", new: "a\nb", changed: []int{1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := difftest.Texts(tt.old, tt.new)
+			var changed []int
+			for i := range result.Lines {
+				if result.EOFChanged(i) {
+					changed = append(changed, i)
+				}
+			}
+			if fmt.Sprint(changed) != fmt.Sprint(tt.changed) {
+				t.Errorf("EOFChanged lines = %v, want %v", changed, tt.changed)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+// Package difftest builds diffs of small texts for the tests of the diff and
+// export packages.
+package difftest
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cj3636/gdiff/internal/diff"
+)
+
+// Texts diffs two file contents the way DiffFiles does, naming them old.txt
+// and new.txt.
+func Texts(old, new string) *diff.DiffResult {
+	result := diff.NewEngine(diff.EngineOptions{}).DiffLines(Lines(old), Lines(new), "old.txt", "new.txt")
+	result.File1NoEOL = diff.MissingFinalNewline([]byte(old))
+	result.File2NoEOL = diff.MissingFinalNewline([]byte(new))
+	return result
+}
+
+// Lines splits file content into lines, dropping the final newline.
+func Lines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// Numbered returns lines 1 to n, with the lines in replace swapped for x<n>.
+func Numbered(n int, replace ...int) string {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		line := fmt.Sprint(i)
+		for _, r := range replace {
+			if r == i {
+				line = "x" + line
+			}
+		}
+		b.WriteString(line + "\n")
+	}
+	return b.String()
+}
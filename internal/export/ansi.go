@@ -0,0 +1,202 @@
+package export
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/cj3636/gdiff/internal/config"
+	"github.com/cj3636/gdiff/internal/diff"
+)
+
+// ColorDepth is the range of colours ANSI output may use.
+type ColorDepth int
+
+const (
+	// ColorBasic uses the eight standard colours and marks changed tokens
+	// with reverse video.
+	ColorBasic ColorDepth = iota
+	// Color256 maps theme colours onto the xterm 256-colour palette.
+	Color256
+	// ColorTrue uses the theme colours as 24-bit RGB.
+	ColorTrue
+)
+
+const ansiReset = "\u001b[0m"
+
+// ParseColorDepth accepts 16, 256, truecolor (or 24bit), and auto, which
+// consults the environment.
+func ParseColorDepth(raw string) (ColorDepth, error) {
+	switch strings.ToLower(raw) {
+	case "", "auto":
+		return DetectColorDepth(), nil
+	case "16", "8", "basic":
+		return ColorBasic, nil
+	case "256":
+		return Color256, nil
+	case "truecolor", "24bit":
+		return ColorTrue, nil
+	default:
+		return ColorBasic, fmt.Errorf("unsupported colour depth: %s", raw)
+	}
+}
+
+// DetectColorDepth guesses the depth of the current terminal from COLORTERM
+// and TERM. Exports are often redirected, so whether stdout is a terminal
+// does not matter.
+func DetectColorDepth() ColorDepth {
+	switch strings.ToLower(os.Getenv("COLORTERM")) {
+	case "truecolor", "24bit":
+		return ColorTrue
+	}
+	if strings.Contains(os.Getenv("TERM"), "256color") {
+		return Color256
+	}
+	return ColorBasic
+}
+
+// ansiPalette holds the escape sequences for one export.
+type ansiPalette struct {
+	depth   ColorDepth
+	lineNo  string
+	fg      map[diff.LineType]string
+	markOn  map[diff.LineType]string
+	markOff string
+}
+
+func newANSIPalette(theme *config.Theme, depth ColorDepth) ansiPalette {
+	if depth == ColorBasic {
+		return ansiPalette{
+			depth:  depth,
+			lineNo: "\u001b[90m",
+			fg: map[diff.LineType]string{
+				diff.Added:   "\u001b[32m",
+				diff.Removed: "\u001b[31m",
+				diff.Equal:   "\u001b[37m",
+			},
+			markOn: map[diff.LineType]string{
+				diff.Added:   "\u001b[1;7m",
+				diff.Removed: "\u001b[1;7m",
+			},
+			markOff: "\u001b[22;27m",
+		}
+	}
+
+	if theme == nil {
+		t := config.DefaultTheme()
+		theme = &t
+	}
+	return ansiPalette{
+		depth:  depth,
+		lineNo: sgrColor(theme.LineNumberFg, depth, false),
+		fg: map[diff.LineType]string{
+			diff.Added:   sgrColor(theme.AddedFg, depth, false),
+			diff.Removed: sgrColor(theme.RemovedFg, depth, false),
+			diff.Equal:   sgrColor(theme.UnchangedFg, depth, false),
+		},
+		markOn: map[diff.LineType]string{
+			diff.Added:   "\u001b[1m" + sgrColor(theme.AddedBg, depth, true),
+			diff.Removed: "\u001b[1m" + sgrColor(theme.RemovedBg, depth, true),
+		},
+		markOff: "\u001b[22;49m",
+	}
+}
+
+// sgrColor returns the escape sequence selecting a hex colour as foreground or
+// background. Colours that are not #rrggbb yield no sequence.
+func sgrColor(color lipgloss.Color, depth ColorDepth, background bool) string {
+	var r, g, b int
+	if _, err := fmt.Sscanf(string(color), "#%02x%02x%02x", &r, &g, &b); err != nil {
+		return ""
+	}
+
+	layer := 38
+	if background {
+		layer = 48
+	}
+	if depth == ColorTrue {
+		return fmt.Sprintf("\u001b[%d;2;%d;%d;%dm", layer, r, g, b)
+	}
+	return fmt.Sprintf("\u001b[%d;5;%dm", layer, xterm256(r, g, b))
+}
+
+// xterm256 picks the nearest entry of the 6x6x6 colour cube or the grey ramp.
+func xterm256(r, g, b int) int {
+	level := func(v int) int {
+		switch {
+		case v < 48:
+			return 0
+		case v < 115:
+			return 1
+		default:
+			return (v - 35) / 40
+		}
+	}
+	value := func(l int) int {
+		if l == 0 {
+			return 0
+		}
+		return 55 + l*40
+	}
+	distance := func(r2, g2, b2 int) int {
+		return (r-r2)*(r-r2) + (g-g2)*(g-g2) + (b-b2)*(b-b2)
+	}
+
+	cr, cg, cb := level(r), level(g), level(b)
+	cube := 16 + 36*cr + 6*cg + cb
+	cubeDistance := distance(value(cr), value(cg), value(cb))
+
+	grey := min(23, max(0, ((r+g+b)/3-3)/10))
+	greyValue := 8 + grey*10
+	if distance(greyValue, greyValue, greyValue) < cubeDistance {
+		return 232 + grey
+	}
+	return cube
+}
+
+// renderANSI colours each line by its type and emphasises the changed tokens
+// within it: bold on the theme background colour, or reverse video when only
+// basic colours are available.
+func renderANSI(result *diff.DiffResult, opts Options) string {
+	palette := newANSIPalette(opts.Theme, opts.Colors)
+
+	var b strings.Builder
+	if opts.Title != "" {
+		fmt.Fprintf(&b, "%s\n\n", opts.Title)
+	}
+
+	for _, line := range result.Lines {
+		color := palette.fg[line.Type]
+		symbol := lineSymbol(line.Type)
+		if opts.ShowLineNumbers {
+			fmt.Fprintf(&b, "%s %s ", palette.renderLineNo(line.LineNo1), palette.renderLineNo(line.LineNo2))
+		}
+		fmt.Fprintf(&b, "%s%s %s%s\n", color, symbol, palette.content(line), ansiReset)
+	}
+	return b.String()
+}
+
+func (p ansiPalette) content(line diff.DiffLine) string {
+	markOn := p.markOn[line.Type]
+	if markOn == "" {
+		return line.Content
+	}
+
+	var b strings.Builder
+	for _, seg := range highlightSegments(line) {
+		if seg.marked {
+			b.WriteString(markOn + seg.text + p.markOff)
+		} else {
+			b.WriteString(seg.text)
+		}
+	}
+	return b.String()
+}
+
+func (p ansiPalette) renderLineNo(no int) string {
+	if no <= 0 {
+		return "     "
+	}
+	return fmt.Sprintf("%s%5d%s", p.lineNo, no, ansiReset)
+}
@@ -2,18 +2,158 @@ package export
 
 import (
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"os/exec"
+	"runtime"
+	"strings"
 )
 
-// CopyToClipboard writes the content to the terminal clipboard using OSC52.
-// The writer defaults to stdout when nil.
-func CopyToClipboard(content string, w io.Writer) error {
+// OSC52Limit is the largest base64 payload sent in one OSC52 sequence. xterm
+// refuses longer ones by default and other terminals truncate them silently.
+const OSC52Limit = 100000
+
+// screenChunk is how much of a sequence fits in one screen passthrough
+// string; screen drops longer ones.
+const screenChunk = 76
+
+// clipboardTool is a command that reads the clipboard contents on stdin.
+type clipboardTool struct {
+	name    string
+	args    []string
+	display string // environment variable that must be set, if any
+	goos    string // operating system it is limited to, if any
+	wsl     bool   // only under the Windows Subsystem for Linux
+}
+
+var clipboardTools = []clipboardTool{
+	{name: "wl-copy", display: "WAYLAND_DISPLAY"},
+	{name: "xclip", args: []string{"-selection", "clipboard"}, display: "DISPLAY"},
+	{name: "xsel", args: []string{"--clipboard", "--input"}, display: "DISPLAY"},
+	{name: "pbcopy", goos: "darwin"},
+	{name: "clip.exe", goos: "linux", wsl: true},
+}
+
+// ClipboardCopy describes how content was put on the clipboard.
+type ClipboardCopy struct {
+	// Backend names the tool or escape sequence that took the content.
+	Backend string
+	// Confirmed is set when the backend reported success. Terminals do not
+	// answer OSC52, so a copy sent that way may have been dropped.
+	Confirmed bool
+}
+
+// CopyToClipboard copies content with the first available of wl-copy,
+// xclip, xsel, pbcopy and clip.exe. Without one it sets the terminal
+// clipboard with OSC52 written to w, which defaults to stdout, as long as w
+// is a terminal, the content fits in OSC52Limit, and any tmux in between
+// will pass the sequence on.
+func CopyToClipboard(content string, w io.Writer) (ClipboardCopy, error) {
 	if w == nil {
 		w = os.Stdout
 	}
-	encoded := base64.StdEncoding.EncodeToString([]byte(content))
-	_, err := fmt.Fprintf(w, "\u001b]52;c;%s\u0007", encoded)
-	return err
+
+	var failures []string
+	for _, tool := range clipboardTools {
+		if tool.goos != "" && tool.goos != runtime.GOOS || tool.display != "" && os.Getenv(tool.display) == "" ||
+			tool.wsl && !isWSL() {
+			continue
+		}
+		if _, err := exec.LookPath(tool.name); err != nil {
+			continue
+		}
+		// wl-copy, xclip and xsel leave a child owning the selection, and
+		// it inherits any output pipes; reading them would wait until
+		// something else is copied. With Stdout and Stderr unset the tool
+		// writes to the null device and only the tool itself is waited for.
+		cmd := exec.Command(tool.name, tool.args...)
+		cmd.Stdin = strings.NewReader(content)
+		if err := cmd.Run(); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", tool.name, err))
+			continue
+		}
+		return ClipboardCopy{Backend: tool.name, Confirmed: true}, nil
+	}
+
+	var sequence, backend string
+	err := errors.New("output is not a terminal")
+	if isTerminal(w) {
+		sequence, backend, err = osc52Sequence(base64.StdEncoding.EncodeToString([]byte(content)))
+	}
+	if err == nil {
+		if _, err := io.WriteString(w, sequence); err != nil {
+			return ClipboardCopy{}, err
+		}
+		return ClipboardCopy{Backend: backend}, nil
+	}
+
+	if len(failures) > 0 {
+		return ClipboardCopy{}, fmt.Errorf("clipboard copy failed: %s", strings.Join(failures, "; "))
+	}
+	return ClipboardCopy{}, fmt.Errorf("no clipboard available: %w, and wl-copy, xclip or xsel were not found", err)
+}
+
+// osc52Sequence builds the OSC52 sequence setting the clipboard to encoded,
+// wrapped to get through tmux or screen, and names the route taken. It
+// fails when the sequence would not reach the terminal.
+func osc52Sequence(encoded string) (string, string, error) {
+	if len(encoded) > OSC52Limit {
+		return "", "", fmt.Errorf("%d bytes is over the terminal clipboard limit of %d", len(encoded), OSC52Limit)
+	}
+
+	sequence := "\u001b]52;c;" + encoded + "\u0007"
+	switch {
+	case os.Getenv("TMUX") != "":
+		// tmux forwards DCS strings, with the escapes inside them doubled,
+		// when allow-passthrough is on; tmux ≥3.3 has it off by default.
+		// With set-clipboard on it takes the bare sequence itself instead.
+		passthrough, clipboard := tmuxOption("-gv", "allow-passthrough"), tmuxOption("-sv", "set-clipboard")
+		switch {
+		case passthrough == "on" || passthrough == "all":
+			return "\u001bPtmux;" + strings.ReplaceAll(sequence, "\u001b", "\u001b\u001b") + "\u001b\\", "OSC52 via tmux", nil
+		case clipboard == "on":
+			return sequence, "OSC52 via tmux", nil
+		default:
+			return "", "", errors.New("tmux has allow-passthrough and set-clipboard off")
+		}
+	case os.Getenv("STY") != "":
+		var b strings.Builder
+		for start := 0; start < len(sequence); start += screenChunk {
+			b.WriteString("\u001bP" + sequence[start:min(start+screenChunk, len(sequence))] + "\u001b\\")
+		}
+		return b.String(), "OSC52 via screen", nil
+	default:
+		return sequence, "OSC52", nil
+	}
+}
+
+// isWSL reports whether this is Linux running under WSL, where clip.exe
+// reaches the Windows clipboard.
+func isWSL() bool {
+	if os.Getenv("WSL_DISTRO_NAME") != "" {
+		return true
+	}
+	version, err := os.ReadFile("/proc/version")
+	return err == nil && strings.Contains(strings.ToLower(string(version)), "microsoft")
+}
+
+// tmuxOption reads a tmux option, empty when tmux cannot be asked. Tests
+// replace it to stand in for a tmux server.
+var tmuxOption = func(flags, name string) string {
+	out, err := exec.Command("tmux", "show", flags, name).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
 }
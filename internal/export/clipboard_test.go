@@ -0,0 +1,123 @@
+package export
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOSC52Sequence(t *testing.T) {
+	const payload = "aGk="
+	bare := "\x1b]52;c;" + payload + "\a"
+	long := strings.Repeat("A", 100)
+	longSequence := "\x1b]52;c;" + long + "\a"
+
+	tests := []struct {
+		name     string
+		tmux     string
+		screen   string
+		options  map[string]string // tmux options, unset when tmux cannot be asked
+		encoded  string
+		expected string
+		backend  string
+		err      string
+	}{
+		{name: "terminal", encoded: payload, expected: bare, backend: "OSC52"},
+		{
+			name:     "tmux passthrough",
+			tmux:     "/tmp/tmux-0/default,1,0",
+			options:  map[string]string{"allow-passthrough": "on", "set-clipboard": "external"},
+			encoded:  payload,
+			expected: "\x1bPtmux;\x1b\x1b]52;c;" + payload + "\a\x1b\\",
+			backend:  "OSC52 via tmux",
+		},
+		{
+			name:     "tmux passthrough for all panes",
+			tmux:     "/tmp/tmux-0/default,1,0",
+			options:  map[string]string{"allow-passthrough": "all"},
+			encoded:  payload,
+			expected: "\x1bPtmux;\x1b\x1b]52;c;" + payload + "\a\x1b\\",
+			backend:  "OSC52 via tmux",
+		},
+		{
+			name:     "tmux sets the clipboard itself",
+			tmux:     "/tmp/tmux-0/default,1,0",
+			options:  map[string]string{"allow-passthrough": "off", "set-clipboard": "on"},
+			encoded:  payload,
+			expected: bare,
+			backend:  "OSC52 via tmux",
+		},
+		{
+			name:    "tmux passes nothing on",
+			tmux:    "/tmp/tmux-0/default,1,0",
+			options: map[string]string{"allow-passthrough": "off", "set-clipboard": "external"},
+			encoded: payload,
+			err:     "allow-passthrough and set-clipboard off",
+		},
+		{
+			name:    "tmux cannot be asked",
+			tmux:    "/tmp/tmux-0/default,1,0",
+			encoded: payload,
+			err:     "allow-passthrough and set-clipboard off",
+		},
+		{
+			name:     "tmux inside screen",
+			tmux:     "/tmp/tmux-0/default,1,0",
+			screen:   "1234.pts-0.host",
+			options:  map[string]string{"set-clipboard": "on"},
+			encoded:  payload,
+			expected: bare,
+			backend:  "OSC52 via tmux",
+		},
+		{
+			name:     "screen",
+			screen:   "1234.pts-0.host",
+			encoded:  payload,
+			expected: "\x1bP" + bare + "\x1b\\",
+			backend:  "OSC52 via screen",
+		},
+		{
+			name:     "screen in chunks",
+			screen:   "1234.pts-0.host",
+			encoded:  long,
+			expected: "\x1bP" + longSequence[:screenChunk] + "\x1b\\\x1bP" + longSequence[screenChunk:] + "\x1b\\",
+			backend:  "OSC52 via screen",
+		},
+		{name: "over the limit", encoded: strings.Repeat("A", OSC52Limit+1), err: "over the terminal clipboard limit"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TMUX", tt.tmux)
+			t.Setenv("STY", tt.screen)
+			saved := tmuxOption
+			t.Cleanup(func() { tmuxOption = saved })
+			tmuxOption = func(flags, name string) string { return tt.options[name] }
+
+			sequence, backend, err := osc52Sequence(tt.encoded)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("osc52Sequence() error = %v, want it to contain %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sequence != tt.expected || backend != tt.backend {
+				t.Errorf("osc52Sequence() = %q, %q, want %q, %q", sequence, backend, tt.expected, tt.backend)
+			}
+		})
+	}
+}
+
+func TestCopyToClipboardWithoutTerminal(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	var out strings.Builder
+	_, err := CopyToClipboard("text", &out)
+	if err == nil || !strings.Contains(err.Error(), "output is not a terminal") {
+		t.Errorf("CopyToClipboard() error = %v, want no terminal", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("CopyToClipboard() wrote %q", out.String())
+	}
+}
@@ -4,9 +4,9 @@ import (
 	"errors"
 	"fmt"
 	"html"
-	"path/filepath"
 	"strings"
 
+	"github.com/cj3636/gdiff/internal/config"
 	"github.com/cj3636/gdiff/internal/diff"
 )
 
@@ -18,16 +18,65 @@ const (
 	FormatHTML Format = "html"
 	// FormatMarkdown emits a Markdown diff code block.
 	FormatMarkdown Format = "markdown"
+	// FormatMarkdownHTML emits Markdown whose diff block is HTML, so comment
+	// previews on GitHub and GitLab can show changed tokens.
+	FormatMarkdownHTML Format = "markdown-html"
 	// FormatANSI emits an ANSI-colored string.
 	FormatANSI Format = "ansi"
+	// FormatJSON emits the diff as one JSON document; see JSONSchema.
+	FormatJSON Format = "json"
+	// FormatNDJSON emits the diff as newline-delimited JSON records.
+	FormatNDJSON Format = "ndjson"
+	// FormatTemplate executes the user template named by Options.Template.
+	FormatTemplate Format = "template"
+	// FormatSVG draws the rendered viewer rows in Options.View as an image.
+	FormatSVG Format = "svg"
+	// FormatPatch emits a unified diff that git apply and patch -p1 accept.
+	FormatPatch Format = "patch"
+	// FormatNormal emits the default output of diff(1).
+	FormatNormal Format = "normal"
+	// FormatContext emits a context diff, as diff -c does.
+	FormatContext Format = "context"
+	// FormatEd emits an ed script, as diff -e does.
+	FormatEd Format = "ed"
+	// FormatSideBySide emits two columns of plain text, as diff -y does.
+	FormatSideBySide Format = "side-by-side"
 )
 
+// DevNull names the missing side of a patch for added or deleted files.
+const DevNull = "/dev/null"
+
 // Options control how a diff is exported.
 type Options struct {
 	// Title will be shown in HTML/Markdown outputs when provided.
 	Title string
 	// ShowLineNumbers determines whether line numbers are included.
 	ShowLineNumbers bool
+	// Context is the number of unchanged lines around each patch hunk.
+	Context int
+	// OldPath and NewPath name the files in patch headers, defaulting to
+	// the DiffResult file names. DevNull marks an added or deleted file.
+	OldPath string
+	NewPath string
+	// Width is the total width of side-by-side text, DefaultWidth when zero.
+	Width int
+	// SideBySide lays HTML out in two paired columns instead of one.
+	SideBySide bool
+	// Theme colours HTML and ANSI output; nil uses the default theme.
+	Theme *config.Theme
+	// Colors is the colour depth of ANSI output.
+	Colors ColorDepth
+	// Template is the path of the template file for FormatTemplate.
+	Template string
+	// View holds the rows of the viewer, styled with ANSI escapes, for
+	// FormatSVG.
+	View []string
+	// Lines limits the export to these spans of DiffResult.Lines, and Hunks
+	// to these hunks, numbered from zero in the order DiffResult.Hunks
+	// returns them for Context. The export covers both when both are set,
+	// and the whole diff when neither is.
+	Lines []LineRange
+	Hunks []int
 }
 
 // Render returns the diff in the requested format.
@@ -36,60 +85,44 @@ func Render(result *diff.DiffResult, format Format, opts Options) (string, error
 		return "", errors.New("diff result is nil")
 	}
 
-	switch strings.ToLower(string(format)) {
+	name := strings.ToLower(string(format))
+	result, err := scopeResult(result, name, opts)
+	if err != nil {
+		return "", err
+	}
+
+	switch name {
 	case string(FormatHTML):
 		return renderHTML(result, opts), nil
 	case string(FormatMarkdown), "md":
 		return renderMarkdown(result, opts), nil
+	case string(FormatMarkdownHTML), "gfm":
+		return renderMarkdownHTML(result, opts), nil
 	case string(FormatANSI), "text":
 		return renderANSI(result, opts), nil
+	case string(FormatJSON):
+		return renderJSON(result, opts)
+	case string(FormatNDJSON), "jsonl":
+		return renderNDJSON(result, opts)
+	case string(FormatTemplate):
+		return renderTemplate(result, opts)
+	case string(FormatSVG):
+		return renderSVG(opts)
+	case string(FormatPatch), "diff", "unified":
+		return renderPatch(result, opts), nil
+	case string(FormatNormal):
+		return renderNormal(result), nil
+	case string(FormatContext):
+		return renderContext(result, opts), nil
+	case string(FormatEd):
+		return renderEd(result), nil
+	case string(FormatSideBySide), "columns":
+		return renderSideBySideText(result, opts), nil
 	default:
 		return "", fmt.Errorf("unsupported export format: %s", format)
 	}
 }
 
-func renderHTML(result *diff.DiffResult, opts Options) string {
-	var b strings.Builder
-
-	b.WriteString("<!DOCTYPE html>\n<html><head><meta charset=\"utf-8\">")
-	b.WriteString("<style>body{background:#0f111a;color:#e5e7eb;font-family:Menlo,Consolas,monospace;}" +
-		"pre{white-space:pre-wrap;word-wrap:break-word;}" +
-		".added{background:#12281a;color:#8dd39e;}" +
-		".removed{background:#2b1313;color:#f19999;}" +
-		".unchanged{color:#cbd5e1;}" +
-		".lineno{color:#9ca3af;margin-right:12px;}" +
-		"h1{font-size:18px;margin-bottom:12px;}" +
-		"</style></head><body>")
-
-	title := opts.Title
-	if title == "" {
-		base1 := filepath.Base(result.File1Name)
-		base2 := filepath.Base(result.File2Name)
-		title = fmt.Sprintf("Diff: %s ↔ %s", base1, base2)
-	}
-	b.WriteString(fmt.Sprintf("<h1>%s</h1>\n<pre>", html.EscapeString(title)))
-
-	for _, line := range result.Lines {
-		class, symbol := classifyLine(line)
-		content := html.EscapeString(line.Content)
-		prefix := symbol
-		if opts.ShowLineNumbers {
-			prefix = fmt.Sprintf("%s %s %s", renderLineNoHTML(line.LineNo1), renderLineNoHTML(line.LineNo2), symbol)
-		}
-		fmt.Fprintf(&b, "<div class=\"%s\">%s%s</div>\n", class, prefix, content)
-	}
-
-	b.WriteString("</pre></body></html>")
-	return b.String()
-}
-
-func renderLineNoHTML(no int) string {
-	if no <= 0 {
-		return "<span class=\"lineno\">&nbsp;&nbsp;&nbsp;&nbsp;&nbsp;</span>"
-	}
-	return fmt.Sprintf("<span class=\"lineno\">%5d</span>", no)
-}
-
 func renderMarkdown(result *diff.DiffResult, opts Options) string {
 	var b strings.Builder
 
@@ -112,36 +145,42 @@ func renderMarkdown(result *diff.DiffResult, opts Options) string {
 	return b.String()
 }
 
-func renderANSI(result *diff.DiffResult, opts Options) string {
+// renderMarkdownHTML writes the diff as a <pre> block, which GitHub and
+// GitLab render in comments, with changed tokens in <ins> and <del>. Fenced
+// code blocks would show the tags literally.
+func renderMarkdownHTML(result *diff.DiffResult, opts Options) string {
 	var b strings.Builder
-	title := opts.Title
-	if title != "" {
-		fmt.Fprintf(&b, "%s\n\n", title)
+
+	if opts.Title != "" {
+		b.WriteString("# ")
+		b.WriteString(opts.Title)
+		b.WriteString("\n\n")
 	}
 
+	b.WriteString("<pre>\n")
 	for _, line := range result.Lines {
 		symbol := lineSymbol(line.Type)
-		color := ansiColor(line.Type)
-		reset := "\u001b[0m"
 		if opts.ShowLineNumbers {
-			prefix := fmt.Sprintf("%s %s %s", renderLineNoColored(line.LineNo1), renderLineNoColored(line.LineNo2), color+symbol+reset)
-			fmt.Fprintf(&b, "%s %s%s%s\n", prefix, color, line.Content, reset)
+			fmt.Fprintf(&b, "%s %5s %5s ", symbol, renderLineNo(line.LineNo1), renderLineNo(line.LineNo2))
 		} else {
-			fmt.Fprintf(&b, "%s%s %s%s\n", color, symbol, line.Content, reset)
+			b.WriteString(symbol + " ")
 		}
-	}
-	return b.String()
-}
 
-func classifyLine(line diff.DiffLine) (class, symbol string) {
-	switch line.Type {
-	case diff.Added:
-		return "added", "+"
-	case diff.Removed:
-		return "removed", "-"
-	default:
-		return "unchanged", " "
+		tag := "ins"
+		if line.Type == diff.Removed {
+			tag = "del"
+		}
+		for _, seg := range highlightSegments(line) {
+			text := html.EscapeString(seg.text)
+			if seg.marked {
+				text = fmt.Sprintf("<%s><b>%s</b></%s>", tag, text, tag)
+			}
+			b.WriteString(text)
+		}
+		b.WriteByte('\n')
 	}
+	b.WriteString("</pre>\n")
+	return b.String()
 }
 
 func lineSymbol(t diff.LineType) string {
@@ -161,21 +200,3 @@ func renderLineNo(no int) string {
 	}
 	return fmt.Sprintf("%d", no)
 }
-
-func renderLineNoColored(no int) string {
-	if no <= 0 {
-		return "     "
-	}
-	return fmt.Sprintf("\u001b[90m%5d\u001b[0m", no)
-}
-
-func ansiColor(t diff.LineType) string {
-	switch t {
-	case diff.Added:
-		return "\u001b[32m"
-	case diff.Removed:
-		return "\u001b[31m"
-	default:
-		return "\u001b[37m"
-	}
-}
@@ -0,0 +1,329 @@
+package export
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cj3636/gdiff/internal/diff"
+)
+
+// DefaultWidth is the total width of side-by-side text output, as in diff -y.
+const DefaultWidth = 130
+
+// lineGroup is either one unchanged line or a run of removed and added lines.
+// oldBefore and newBefore count the lines of each file preceding it.
+type lineGroup struct {
+	equal     int
+	removed   []int
+	added     []int
+	oldBefore int
+	newBefore int
+}
+
+func (g lineGroup) changed() bool {
+	return g.equal < 0
+}
+
+// groupLines splits Lines[start:end] into unchanged lines and change runs. A
+// shared last line that differs only in its final newline counts as changed.
+func groupLines(result *diff.DiffResult, start, end int) []lineGroup {
+	oldBefore, newBefore := 0, 0
+	for i := 0; i < start; i++ {
+		if result.Lines[i].LineNo1 > 0 {
+			oldBefore++
+		}
+		if result.Lines[i].LineNo2 > 0 {
+			newBefore++
+		}
+	}
+
+	var groups []lineGroup
+	for i := start; i < end; i++ {
+		line := result.Lines[i]
+		if !result.LineChanged(i) {
+			groups = append(groups, lineGroup{equal: i, oldBefore: oldBefore, newBefore: newBefore})
+			oldBefore++
+			newBefore++
+			continue
+		}
+
+		n := len(groups)
+		if n == 0 || !groups[n-1].changed() {
+			groups = append(groups, lineGroup{equal: -1, oldBefore: oldBefore, newBefore: newBefore})
+			n++
+		}
+		if line.LineNo1 > 0 {
+			groups[n-1].removed = append(groups[n-1].removed, i)
+			oldBefore++
+		}
+		if line.LineNo2 > 0 {
+			groups[n-1].added = append(groups[n-1].added, i)
+			newBefore++
+		}
+	}
+	return groups
+}
+
+// writeGNULine writes a line behind prefix, followed by the no-newline marker
+// when it ends its file without one.
+func writeGNULine(b *strings.Builder, result *diff.DiffResult, prefix string, i int, old bool) {
+	line := result.Lines[i]
+	b.WriteString(prefix)
+	b.WriteString(line.Content)
+	b.WriteByte('\n')
+
+	if old && line.LineNo1 == len(result.File1Lines) && result.File1NoEOL ||
+		!old && line.LineNo2 == len(result.File2Lines) && result.File2NoEOL {
+		b.WriteString(noNewlineMarker)
+	}
+}
+
+// gnuRange formats a line range the way normal and ed output do: a single
+// number for one line, first,last otherwise, and the preceding line number
+// for an empty range.
+func gnuRange(before, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d", before)
+	case 1:
+		return fmt.Sprintf("%d", before+1)
+	default:
+		return fmt.Sprintf("%d,%d", before+1, before+count)
+	}
+}
+
+// renderNormal writes the default output of diff(1): 3c3, 5,7d4 and 8a9,10
+// commands followed by the affected lines.
+func renderNormal(result *diff.DiffResult) string {
+	var b strings.Builder
+	for _, g := range groupLines(result, 0, len(result.Lines)) {
+		if !g.changed() {
+			continue
+		}
+
+		oldRange, newRange := gnuRange(g.oldBefore, len(g.removed)), gnuRange(g.newBefore, len(g.added))
+		switch {
+		case len(g.added) == 0:
+			fmt.Fprintf(&b, "%sd%d\n", oldRange, g.newBefore)
+		case len(g.removed) == 0:
+			fmt.Fprintf(&b, "%da%s\n", g.oldBefore, newRange)
+		default:
+			fmt.Fprintf(&b, "%sc%s\n", oldRange, newRange)
+		}
+
+		for _, i := range g.removed {
+			writeGNULine(&b, result, "< ", i, true)
+		}
+		if len(g.removed) > 0 && len(g.added) > 0 {
+			b.WriteString("---\n")
+		}
+		for _, i := range g.added {
+			writeGNULine(&b, result, "> ", i, false)
+		}
+	}
+	return b.String()
+}
+
+// renderContext writes diff -c output: each hunk lists the old lines and then
+// the new ones, marking changed lines with "!", and lines only on one side
+// with "-" or "+". A side without changes in the hunk is left out.
+func renderContext(result *diff.DiffResult, opts Options) string {
+	hunks := result.Hunks(opts.Context)
+	if len(hunks) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "*** %s\n--- %s\n", contextLabel(result.File1Name), contextLabel(result.File2Name))
+	for _, hunk := range hunks {
+		groups := groupLines(result, hunk.Start, hunk.End)
+		b.WriteString("***************\n")
+
+		fmt.Fprintf(&b, "*** %s ****\n", contextRange(hunk.OldStart, hunk.OldLines))
+		if hasChanges(groups, true) {
+			writeContextSide(&b, result, groups, true)
+		}
+		fmt.Fprintf(&b, "--- %s ----\n", contextRange(hunk.NewStart, hunk.NewLines))
+		if hasChanges(groups, false) {
+			writeContextSide(&b, result, groups, false)
+		}
+	}
+	return b.String()
+}
+
+func hasChanges(groups []lineGroup, old bool) bool {
+	for _, g := range groups {
+		if old && len(g.removed) > 0 || !old && len(g.added) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func writeContextSide(b *strings.Builder, result *diff.DiffResult, groups []lineGroup, old bool) {
+	for _, g := range groups {
+		if !g.changed() {
+			writeGNULine(b, result, "  ", g.equal, old)
+			continue
+		}
+
+		marker, lines := "- ", g.removed
+		if !old {
+			marker, lines = "+ ", g.added
+		}
+		if len(g.removed) > 0 && len(g.added) > 0 {
+			marker = "! "
+		}
+		for _, i := range lines {
+			writeGNULine(b, result, marker, i, old)
+		}
+	}
+}
+
+// contextRange formats a hunk range as first,last, which diff -c uses in
+// place of the unified start,count.
+func contextRange(start, count int) string {
+	switch count {
+	case 0, 1:
+		return fmt.Sprintf("%d", start)
+	default:
+		return fmt.Sprintf("%d,%d", start, start+count-1)
+	}
+}
+
+// contextLabel appends the modification time for files that exist on disk,
+// as diff -c does.
+func contextLabel(name string) string {
+	info, err := os.Stat(name)
+	if err != nil {
+		return name
+	}
+	return name + "\t" + info.ModTime().Format("Mon Jan _2 15:04:05 2006")
+}
+
+// renderEd writes an ed script that turns the old file into the new one. The
+// commands run from the end of the file backwards so earlier line numbers stay
+// valid. A line consisting of a single "." cannot be entered directly, so it
+// is written as ".." and fixed up with a substitution.
+func renderEd(result *diff.DiffResult) string {
+	// ed cannot express a change to a final newline, so lines differing
+	// only in one are left as they are.
+	plain := *result
+	plain.File1NoEOL, plain.File2NoEOL = false, false
+	groups := groupLines(&plain, 0, len(result.Lines))
+
+	var b strings.Builder
+	for k := len(groups) - 1; k >= 0; k-- {
+		g := groups[k]
+		if !g.changed() {
+			continue
+		}
+
+		oldRange := gnuRange(g.oldBefore, len(g.removed))
+		switch {
+		case len(g.added) == 0:
+			fmt.Fprintf(&b, "%sd\n", oldRange)
+			continue
+		case len(g.removed) == 0:
+			fmt.Fprintf(&b, "%da\n", g.oldBefore)
+		default:
+			fmt.Fprintf(&b, "%sc\n", oldRange)
+		}
+
+		for n, i := range g.added {
+			content := result.Lines[i].Content
+			if content != "." {
+				b.WriteString(content)
+				b.WriteByte('\n')
+				continue
+			}
+			b.WriteString("..\n.\ns/.//\n")
+			if n < len(g.added)-1 {
+				b.WriteString("a\n")
+			}
+		}
+		if last := result.Lines[g.added[len(g.added)-1]].Content; last != "." {
+			b.WriteString(".\n")
+		}
+	}
+	return b.String()
+}
+
+// renderSideBySideText writes diff -y output with tabs expanded: the old line,
+// a gutter marker and the new line, each column cut to fit width. Markers are
+// "|" changed, "<" removed, ">" added, and "\" or "/" when only the old or
+// the new line lacks its final newline.
+func renderSideBySideText(result *diff.DiffResult, opts Options) string {
+	width := opts.Width
+	if width <= 0 {
+		width = DefaultWidth
+	}
+	// Column geometry follows GNU diff with --expand-tabs.
+	offset := (width + 1 + 3) / 2
+	half := max(0, min(offset-3, width-offset))
+
+	var b strings.Builder
+	row := func(left, marker, right string) {
+		left = fitColumn(left, half)
+		if marker == " " && right == "" {
+			b.WriteString(strings.TrimRight(left, " "))
+			b.WriteByte('\n')
+			return
+		}
+		line := fmt.Sprintf("%-*s %s", half, left, marker)
+		if right != "" {
+			line = fmt.Sprintf("%-*s%s", offset, line, fitColumn(right, half))
+		}
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+
+	for _, g := range groupLines(result, 0, len(result.Lines)) {
+		if !g.changed() {
+			content := result.Lines[g.equal].Content
+			row(content, " ", content)
+			continue
+		}
+
+		for k := 0; k < max(len(g.removed), len(g.added)); k++ {
+			switch {
+			case k < len(g.removed) && k < len(g.added):
+				marker := "|"
+				if g.removed[k] == g.added[k] {
+					marker = "/"
+					if result.File1NoEOL {
+						marker = "\\"
+					}
+				}
+				row(result.Lines[g.removed[k]].Content, marker, result.Lines[g.added[k]].Content)
+			case k < len(g.removed):
+				row(result.Lines[g.removed[k]].Content, "<", "")
+			default:
+				row("", ">", result.Lines[g.added[k]].Content)
+			}
+		}
+	}
+	return b.String()
+}
+
+// fitColumn expands tabs and cuts text to width runes.
+func fitColumn(text string, width int) string {
+	var b strings.Builder
+	col := 0
+	for _, r := range text {
+		if r == '\t' {
+			next := (col/8 + 1) * 8
+			for ; col < next && col < width; col++ {
+				b.WriteByte(' ')
+			}
+			continue
+		}
+		if col >= width {
+			break
+		}
+		b.WriteRune(r)
+		col++
+	}
+	return b.String()
+}
@@ -0,0 +1,194 @@
+package export
+
+import (
+	"testing"
+
+	"github.com/cj3636/gdiff/internal/difftest"
+)
+
+// The expected output of these tests is that of GNU diff 3 on the same
+// files: diff, diff -c, diff -e and diff -y -t.
+
+func TestRenderNormal(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		expected string
+	}{
+		{name: "identical", old: "a\n", new: "a\n"},
+		{name: "change", old: "a\nb\nc\n", new: "a\nB\nC\n", expected: "2,3c2,3\n< b\n< c\n---\n> B\n> C\n"},
+		{name: "delete", old: "a\ngone\nb\n", new: "a\nb\n", expected: "2d1\n< gone\n"},
+		{name: "add", old: "a\nb\n", new: "a\nnew\nb\n", expected: "1a2\n> new\n"},
+		{
+			name:     "old file lacks a final newline",
+			old:      "a\nb",
+			new:      "a\nb\nc\n",
+			expected: "2c2,3\n< b\n" + noNewlineMarker + "---\n> b\n> c\n",
+		},
+		{
+			name:     "new file drops the final newline",
+			old:      "a\nb\n",
+			new:      "a\nb",
+			expected: "2c2\n< b\n---\n> b\n" + noNewlineMarker,
+		},
+		{
+			name:     "both lack a final newline",
+			old:      "a\nb",
+			new:      "a\nc",
+			expected: "2c2\n< b\n" + noNewlineMarker + "---\n> c\n" + noNewlineMarker,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Render(difftest.Texts(tt.old, tt.new), FormatNormal, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.expected {
+				t.Errorf("Render(normal) =\n%s\nwant\n%s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRenderContext(t *testing.T) {
+	const header = "*** old.txt\n--- new.txt\n***************\n"
+	tests := []struct {
+		name     string
+		old, new string
+		expected string
+	}{
+		{name: "identical", old: "a\n", new: "a\n"},
+		{
+			name:     "change",
+			old:      difftest.Numbered(10),
+			new:      difftest.Numbered(10, 5),
+			expected: header + "*** 2,8 ****\n  2\n  3\n  4\n! 5\n  6\n  7\n  8\n--- 2,8 ----\n  2\n  3\n  4\n! x5\n  6\n  7\n  8\n",
+		},
+		{
+			name:     "a side without changes has no lines",
+			old:      "a\ngone\nb\n",
+			new:      "a\nb\n",
+			expected: header + "*** 1,3 ****\n  a\n- gone\n  b\n--- 1,2 ----\n",
+		},
+		{
+			name:     "old side without changes",
+			old:      "a\nb\n",
+			new:      "a\nnew\nb\n",
+			expected: header + "*** 1,2 ****\n--- 1,3 ----\n  a\n+ new\n  b\n",
+		},
+		{
+			name:     "old file lacks a final newline",
+			old:      "a\nb",
+			new:      "a\nb\nc\n",
+			expected: header + "*** 1,2 ****\n  a\n! b\n" + noNewlineMarker + "--- 1,3 ----\n  a\n! b\n! c\n",
+		},
+		{
+			name:     "new file drops the final newline",
+			old:      "a\nb\n",
+			new:      "a\nb",
+			expected: header + "*** 1,2 ****\n  a\n! b\n--- 1,2 ----\n  a\n! b\n" + noNewlineMarker,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Render(difftest.Texts(tt.old, tt.new), FormatContext, Options{Context: 3})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.expected {
+				t.Errorf("Render(context) =\n%s\nwant\n%s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRenderEd(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		expected string
+	}{
+		{name: "identical", old: "a\n", new: "a\n"},
+		{name: "change", old: "a\nb\nc\n", new: "a\nB\nC\n", expected: "2,3c\nB\nC\n.\n"},
+		{name: "delete", old: "a\ngone\nb\n", new: "a\nb\n", expected: "2d\n"},
+		{name: "add", old: "a\nb\n", new: "a\nnew\nb\n", expected: "1a\nnew\n.\n"},
+		{name: "later changes come first", old: difftest.Numbered(10), new: difftest.Numbered(10, 2, 8), expected: "8c\nx8\n.\n2c\nx2\n.\n"},
+		{name: "a lone dot line", old: "a\nb\n", new: "a\n.\nb\n", expected: "1a\n..\n.\ns/.//\n"},
+		{name: "a dot line followed by more", old: "a\nb\n", new: "a\n.\nx\nb\n", expected: "1a\n..\n.\ns/.//\na\nx\n.\n"},
+		{name: "only the final newline changes", old: "a\nb\n", new: "a\nb"},
+		{name: "lines added after a missing newline", old: "a\nb", new: "a\nb\nc\n", expected: "2a\nc\n.\n"},
+		{name: "last line changes without newlines", old: "a\nb", new: "a\nc", expected: "2c\nc\n.\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Render(difftest.Texts(tt.old, tt.new), FormatEd, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.expected {
+				t.Errorf("Render(ed) =\n%s\nwant\n%s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRenderSideBySideText(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		expected string
+	}{
+		{
+			name:     "change",
+			old:      "a\nb\nc\n",
+			new:      "a\nB\nC\n",
+			expected: "a                a\nb             |  B\nc             |  C\n",
+		},
+		{
+			name:     "delete and add",
+			old:      "a\ngone\nb\n",
+			new:      "a\nb\nnew\n",
+			expected: "a                a\ngone          <\nb                b\n              >  new\n",
+		},
+		{
+			name:     "tabs are expanded",
+			old:      "a\tb\nsame\n",
+			new:      "a\tB\nsame\n",
+			expected: "a       b     |  a       B\nsame             same\n",
+		},
+		{
+			name:     "long lines are cut to the column",
+			old:      "0123456789abcdefghij\n",
+			new:      "0123456789abcdefghiJ\n",
+			expected: "0123456789abc |  0123456789abc\n",
+		},
+		{
+			name:     "old line lacks its newline",
+			old:      "a\nb",
+			new:      "a\nb\nc\n",
+			expected: "a                a\nb             \\  b\n              >  c\n",
+		},
+		{
+			name:     "new line lacks its newline",
+			old:      "a\nb\n",
+			new:      "a\nb",
+			expected: "a                a\nb             /  b\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Render(difftest.Texts(tt.old, tt.new), FormatSideBySide, Options{Width: 30})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.expected {
+				t.Errorf("Render(side-by-side) =\n%s\nwant\n%s", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package export
+
+import "github.com/cj3636/gdiff/internal/diff"
+
+// segment is a stretch of a line that is either inside a changed token range
+// or outside all of them.
+type segment struct {
+	text   string
+	marked bool
+}
+
+// highlightSegments splits a line at its highlight boundaries. A highlight
+// covering the whole line only repeats what the line type already says, so
+// such lines come back as a single unmarked segment.
+func highlightSegments(line diff.DiffLine) []segment {
+	runes := []rune(line.Content)
+	if len(line.Highlights) == 0 || wholeLine(line.Highlights, len(runes)) {
+		return []segment{{text: line.Content}}
+	}
+
+	var segments []segment
+	cursor := 0
+	for _, h := range line.Highlights {
+		start, end := max(h.Start, cursor), min(h.End, len(runes))
+		if start >= end {
+			continue
+		}
+		if start > cursor {
+			segments = append(segments, segment{text: string(runes[cursor:start])})
+		}
+		segments = append(segments, segment{text: string(runes[start:end]), marked: true})
+		cursor = end
+	}
+	if cursor < len(runes) {
+		segments = append(segments, segment{text: string(runes[cursor:])})
+	}
+	return segments
+}
+
+func wholeLine(highlights []diff.Highlight, length int) bool {
+	return len(highlights) == 1 && highlights[0].Start <= 0 && highlights[0].End >= length
+}
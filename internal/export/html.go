@@ -0,0 +1,213 @@
+package export
+
+import (
+	"fmt"
+	"html"
+	"path/filepath"
+	"strings"
+
+	"github.com/cj3636/gdiff/internal/config"
+	"github.com/cj3636/gdiff/internal/diff"
+)
+
+// minCollapsedRun is the shortest run of hidden unchanged lines worth folding
+// away; anything shorter is cheaper to show than to expand.
+const minCollapsedRun = 4
+
+// renderHTML writes a standalone page in the unified or side-by-side layout.
+// Changed tokens are wrapped in <mark>, and unchanged stretches further than
+// Context lines from a change fold into <details> elements.
+func renderHTML(result *diff.DiffResult, opts Options) string {
+	var b strings.Builder
+
+	title := htmlTitle(result, opts)
+	b.WriteString("<!DOCTYPE html>\n<html><head><meta charset=\"utf-8\">")
+	fmt.Fprintf(&b, "<title>%s</title>\n<style>%s</style></head><body>\n", html.EscapeString(title), htmlStyles(opts.Theme))
+	writeHTMLDiff(&b, result, title, opts)
+	b.WriteString("</body></html>\n")
+	return b.String()
+}
+
+func htmlTitle(result *diff.DiffResult, opts Options) string {
+	if opts.Title != "" {
+		return opts.Title
+	}
+	return fmt.Sprintf("Diff: %s ↔ %s", filepath.Base(result.File1Name), filepath.Base(result.File2Name))
+}
+
+// writeHTMLDiff writes the heading, stats and rows of a diff page.
+func writeHTMLDiff(b *strings.Builder, result *diff.DiffResult, title string, opts Options) {
+	added, removed, _ := result.GetStats()
+	fmt.Fprintf(b, "<h1>%s</h1>\n", html.EscapeString(title))
+	fmt.Fprintf(b, "<p class=\"stats\"><span class=\"added\">+%d</span> <span class=\"removed\">-%d</span></p>\n", added, removed)
+
+	layout := "unified"
+	if opts.SideBySide {
+		layout = "split"
+	}
+	if !opts.ShowLineNumbers {
+		layout += " nolineno"
+	}
+	fmt.Fprintf(b, "<div class=\"diff %s\">\n", layout)
+	writeHTMLRows(b, result, opts)
+	b.WriteString("</div>\n")
+}
+
+// writeHTMLRows emits the rows of the diff, folding unchanged runs away from
+// the changes.
+func writeHTMLRows(b *strings.Builder, result *diff.DiffResult, opts Options) {
+	groups := groupLines(result, 0, len(result.Lines))
+	visible := make([]bool, len(groups))
+	for g := range groups {
+		if !groups[g].changed() {
+			continue
+		}
+		for k := max(0, g-opts.Context); k <= min(len(groups)-1, g+opts.Context); k++ {
+			visible[k] = true
+		}
+	}
+
+	for g := 0; g < len(groups); {
+		if visible[g] {
+			writeHTMLGroup(b, result, groups[g], opts)
+			g++
+			continue
+		}
+
+		end := g
+		for end < len(groups) && !visible[end] {
+			end++
+		}
+		folded := end-g >= minCollapsedRun
+		if folded {
+			fmt.Fprintf(b, "<details class=\"fold\"><summary>%d unchanged lines</summary>\n", end-g)
+		}
+		for ; g < end; g++ {
+			writeHTMLGroup(b, result, groups[g], opts)
+		}
+		if folded {
+			b.WriteString("</details>\n")
+		}
+	}
+}
+
+func writeHTMLGroup(b *strings.Builder, result *diff.DiffResult, g lineGroup, opts Options) {
+	if !g.changed() {
+		line := result.Lines[g.equal]
+		if opts.SideBySide {
+			writeSplitRow(b, opts, &line, &line, "unchanged")
+		} else {
+			writeUnifiedRow(b, opts, line, "unchanged", " ")
+		}
+		return
+	}
+
+	if !opts.SideBySide {
+		for _, i := range g.removed {
+			writeUnifiedRow(b, opts, result.Lines[i], "removed", "-")
+		}
+		for _, i := range g.added {
+			writeUnifiedRow(b, opts, result.Lines[i], "added", "+")
+		}
+		return
+	}
+
+	for k := 0; k < max(len(g.removed), len(g.added)); k++ {
+		var left, right *diff.DiffLine
+		if k < len(g.removed) {
+			left = &result.Lines[g.removed[k]]
+		}
+		if k < len(g.added) {
+			right = &result.Lines[g.added[k]]
+		}
+		writeSplitRow(b, opts, left, right, "changed")
+	}
+}
+
+func writeUnifiedRow(b *strings.Builder, opts Options, line diff.DiffLine, class, symbol string) {
+	fmt.Fprintf(b, "<div class=\"row %s\">", class)
+	if opts.ShowLineNumbers {
+		fmt.Fprintf(b, "%s%s", htmlLineNo(line.LineNo1), htmlLineNo(line.LineNo2))
+	}
+	fmt.Fprintf(b, "<span class=\"sign\">%s</span><span class=\"code\">%s</span></div>\n", symbol, htmlContent(line))
+}
+
+// writeSplitRow emits one paired row; a nil side is blank filler.
+func writeSplitRow(b *strings.Builder, opts Options, left, right *diff.DiffLine, class string) {
+	fmt.Fprintf(b, "<div class=\"row %s\">", class)
+	for side, line := range []*diff.DiffLine{left, right} {
+		cell := "unchanged"
+		if class == "changed" {
+			cell = "removed"
+			if side == 1 {
+				cell = "added"
+			}
+		}
+		if line == nil {
+			cell = "empty"
+		}
+
+		no, content := 0, ""
+		if line != nil {
+			no, content = line.LineNo2, htmlContent(*line)
+			if side == 0 {
+				no = line.LineNo1
+			}
+		}
+		if opts.ShowLineNumbers {
+			b.WriteString(htmlLineNo(no))
+		}
+		fmt.Fprintf(b, "<span class=\"code %s\">%s</span>", cell, content)
+	}
+	b.WriteString("</div>\n")
+}
+
+// htmlContent escapes a line and marks its changed tokens. A highlight that
+// spans the whole line adds nothing over the row colour and is dropped.
+func htmlContent(line diff.DiffLine) string {
+	var b strings.Builder
+	for _, seg := range highlightSegments(line) {
+		text := html.EscapeString(seg.text)
+		if seg.marked {
+			text = "<mark>" + text + "</mark>"
+		}
+		b.WriteString(text)
+	}
+	return b.String()
+}
+
+func htmlLineNo(no int) string {
+	if no <= 0 {
+		return "<span class=\"lineno\"></span>"
+	}
+	return fmt.Sprintf("<span class=\"lineno\">%d</span>", no)
+}
+
+// htmlStyles builds the stylesheet from theme, or the default theme when nil.
+func htmlStyles(theme *config.Theme) string {
+	if theme == nil {
+		t := config.DefaultTheme()
+		theme = &t
+	}
+
+	return fmt.Sprintf(":root{--added-bg:%s;--added-fg:%s;--removed-bg:%s;--removed-fg:%s;--fg:%s;--lineno:%s;--border:%s;--title-fg:%s;--title-bg:%s;--muted:%s;--bg:%s}",
+		theme.AddedBg, theme.AddedFg, theme.RemovedBg, theme.RemovedFg, theme.UnchangedFg,
+		theme.LineNumberFg, theme.BorderFg, theme.TitleFg, theme.TitleBg, theme.HelpFg, theme.Background) +
+		"body{background:var(--bg);color:var(--fg);font-family:Menlo,Consolas,monospace;font-size:13px;margin:0;}" +
+		"h1{font-size:16px;margin:0;padding:8px 12px;color:var(--title-fg);background:var(--title-bg);}" +
+		".stats{margin:8px 12px;}.stats .added{color:var(--added-fg);}.stats .removed{color:var(--removed-fg);}" +
+		".diff{border-top:1px solid var(--border);}" +
+		".row{display:grid;white-space:pre-wrap;word-break:break-all;}" +
+		".unified .row{grid-template-columns:auto auto 2ch 1fr;}" +
+		".split .row{grid-template-columns:auto 1fr auto 1fr;}" +
+		".unified.nolineno .row{grid-template-columns:2ch 1fr;}.split.nolineno .row{grid-template-columns:1fr 1fr;}" +
+		".lineno{color:var(--lineno);text-align:right;padding:0 8px;min-width:4ch;user-select:none;}" +
+		".split .lineno+.code+.lineno{border-left:1px solid var(--border);}" +
+		".row.added,.code.added{background:color-mix(in srgb,var(--added-bg) 60%,transparent);color:var(--added-fg);}" +
+		".row.removed,.code.removed{background:color-mix(in srgb,var(--removed-bg) 60%,transparent);color:var(--removed-fg);}" +
+		".code.empty{background:repeating-linear-gradient(135deg,transparent 0 6px,var(--border) 6px 7px);}" +
+		"mark{color:inherit;font-weight:bold;border-radius:2px;}" +
+		".added mark{background:var(--added-bg);}.removed mark{background:var(--removed-bg);}" +
+		".fold summary{color:var(--muted);cursor:pointer;padding:2px 12px;background:color-mix(in srgb,var(--bg) 92%,var(--fg));list-style:none;}" +
+		".fold summary::before{content:'⋯ ';}.fold[open] summary::before{content:'▾ ';}"
+}
@@ -0,0 +1,307 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/cj3636/gdiff/internal/diff"
+)
+
+// JSONSchema identifies gdiff documents, and JSONVersion is the schema
+// version written. The version changes when a field is renamed, removed or
+// changes meaning; fields may be added without a bump, so readers should
+// ignore unknown ones.
+//
+// A json export is a single object:
+//
+//	{
+//	  "schema": "gdiff.diff", "version": 1,
+//	  "old": {"path": "a.go", "no_eol": false, "lines": 41},
+//	  "new": {"path": "b.go", "no_eol": true, "lines": 41},
+//	  "binary": false,
+//	  "stats": {"added": 1, "removed": 1, "unchanged": 40},
+//	  "hunks": [{"first_line": 0, "end_line": 7, "old_start": 1, "old_lines": 4,
+//	             "new_start": 1, "new_lines": 4, "header": "@@ -1,4 +1,4 @@ func main() {"}],
+//	  "lines": [{"type": "removed", "content": "x := 1", "old_line": 3,
+//	             "highlights": [{"start": 5, "end": 6}]}]
+//	}
+//
+// The lines of old and new count the whole file, which is more than the
+// lines array holds when the export is limited to part of the diff. Line
+// types are "equal", "added" and "removed"; old_line and new_line are
+// 1-based and omitted on the side a line is missing from. Highlight offsets
+// count runes, end exclusive. Hunks index into lines with first_line and
+// end_line, end exclusive. Empty hunks, lines and highlights are omitted.
+//
+// An ndjson export carries the same data as one record per line, each with a
+// "record" field: a "diff" record with schema, version, old, new and binary;
+// a "line" record per line; then a "hunk" record per hunk and a final
+// "stats" record, so the lines can be consumed before the diff has been
+// fully read.
+const (
+	JSONSchema  = "gdiff.diff"
+	JSONVersion = 1
+)
+
+type jsonDiff struct {
+	Record  string     `json:"record,omitempty"`
+	Schema  string     `json:"schema"`
+	Version int        `json:"version"`
+	Old     jsonFile   `json:"old"`
+	New     jsonFile   `json:"new"`
+	Binary  bool       `json:"binary"`
+	Stats   *jsonStats `json:"stats,omitempty"`
+	Hunks   []jsonHunk `json:"hunks,omitempty"`
+	Lines   []jsonLine `json:"lines,omitempty"`
+}
+
+type jsonFile struct {
+	Path  string `json:"path"`
+	NoEOL bool   `json:"no_eol"`
+	Lines int    `json:"lines"`
+}
+
+type jsonStats struct {
+	Record    string `json:"record,omitempty"`
+	Added     int    `json:"added"`
+	Removed   int    `json:"removed"`
+	Unchanged int    `json:"unchanged"`
+}
+
+type jsonHunk struct {
+	Record    string `json:"record,omitempty"`
+	FirstLine int    `json:"first_line"`
+	EndLine   int    `json:"end_line"`
+	OldStart  int    `json:"old_start"`
+	OldLines  int    `json:"old_lines"`
+	NewStart  int    `json:"new_start"`
+	NewLines  int    `json:"new_lines"`
+	Header    string `json:"header"`
+}
+
+type jsonLine struct {
+	Record     string          `json:"record,omitempty"`
+	Type       string          `json:"type"`
+	Content    string          `json:"content"`
+	OldLine    int             `json:"old_line,omitempty"`
+	NewLine    int             `json:"new_line,omitempty"`
+	Highlights []jsonHighlight `json:"highlights,omitempty"`
+}
+
+type jsonHighlight struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+var lineTypeNames = map[diff.LineType]string{
+	diff.Equal:   "equal",
+	diff.Added:   "added",
+	diff.Removed: "removed",
+}
+
+func renderJSON(result *diff.DiffResult, opts Options) (string, error) {
+	doc := jsonHeader(result)
+	stats := jsonStatsOf(result)
+	doc.Stats = &stats
+	doc.Hunks = jsonHunks(result, opts.Context)
+	doc.Lines = make([]jsonLine, len(result.Lines))
+	for i, line := range result.Lines {
+		doc.Lines[i] = jsonLineOf(line)
+	}
+
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(out) + "\n", nil
+}
+
+func renderNDJSON(result *diff.DiffResult, opts Options) (string, error) {
+	var b strings.Builder
+	if err := writeNDJSON(&b, result, opts); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// WriteNDJSON streams the ndjson export of the diff to w one record at a
+// time, limited to opts.Lines and opts.Hunks as Render would.
+func WriteNDJSON(w io.Writer, result *diff.DiffResult, opts Options) error {
+	if result == nil {
+		return errors.New("diff result is nil")
+	}
+	result, err := scopeResult(result, string(FormatNDJSON), opts)
+	if err != nil {
+		return err
+	}
+	return writeNDJSON(w, result, opts)
+}
+
+func writeNDJSON(w io.Writer, result *diff.DiffResult, opts Options) error {
+	enc := json.NewEncoder(w)
+
+	header := jsonHeader(result)
+	header.Record = "diff"
+	if err := enc.Encode(header); err != nil {
+		return err
+	}
+	for _, line := range result.Lines {
+		record := jsonLineOf(line)
+		record.Record = "line"
+		if err := enc.Encode(record); err != nil {
+			return err
+		}
+	}
+	for _, hunk := range jsonHunks(result, opts.Context) {
+		hunk.Record = "hunk"
+		if err := enc.Encode(hunk); err != nil {
+			return err
+		}
+	}
+	stats := jsonStatsOf(result)
+	stats.Record = "stats"
+	return enc.Encode(stats)
+}
+
+func jsonHeader(result *diff.DiffResult) jsonDiff {
+	return jsonDiff{
+		Schema:  JSONSchema,
+		Version: JSONVersion,
+		Old:     jsonFile{Path: result.File1Name, NoEOL: result.File1NoEOL, Lines: len(result.File1Lines)},
+		New:     jsonFile{Path: result.File2Name, NoEOL: result.File2NoEOL, Lines: len(result.File2Lines)},
+		Binary:  result.Binary,
+	}
+}
+
+func jsonStatsOf(result *diff.DiffResult) jsonStats {
+	added, removed, unchanged := result.GetStats()
+	return jsonStats{Added: added, Removed: removed, Unchanged: unchanged}
+}
+
+func jsonHunks(result *diff.DiffResult, context int) []jsonHunk {
+	var hunks []jsonHunk
+	for _, h := range result.Hunks(context) {
+		hunks = append(hunks, jsonHunk{
+			FirstLine: h.Start,
+			EndLine:   h.End,
+			OldStart:  h.OldStart,
+			OldLines:  h.OldLines,
+			NewStart:  h.NewStart,
+			NewLines:  h.NewLines,
+			Header:    result.HunkHeader(h),
+		})
+	}
+	return hunks
+}
+
+func jsonLineOf(line diff.DiffLine) jsonLine {
+	out := jsonLine{
+		Type:    lineTypeNames[line.Type],
+		Content: line.Content,
+		OldLine: line.LineNo1,
+		NewLine: line.LineNo2,
+	}
+	for _, h := range line.Highlights {
+		out.Highlights = append(out.Highlights, jsonHighlight{Start: h.Start, End: h.End})
+	}
+	return out
+}
+
+// ReadJSON loads a diff written by the json or ndjson export. Hunks and stats
+// are derived from the lines again, so only the lines and file details are
+// read back. The file contents hold the exported lines at their line
+// numbers, with the lines a limited export left out empty.
+func ReadJSON(r io.Reader) (*diff.DiffResult, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var header jsonDiff
+	if err := json.NewDecoder(bytes.NewReader(data)).Decode(&header); err != nil {
+		return nil, fmt.Errorf("not a gdiff JSON diff: %w", err)
+	}
+	if header.Schema != JSONSchema {
+		return nil, errors.New("not a gdiff JSON diff: missing schema")
+	}
+	if header.Version > JSONVersion {
+		return nil, fmt.Errorf("JSON diff version %d is newer than the supported version %d", header.Version, JSONVersion)
+	}
+
+	lines := header.Lines
+	if header.Record == "diff" {
+		lines, err = readNDJSONLines(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result := &diff.DiffResult{
+		File1Name:  header.Old.Path,
+		File2Name:  header.New.Path,
+		File1NoEOL: header.Old.NoEOL,
+		File2NoEOL: header.New.NoEOL,
+		Binary:     header.Binary,
+	}
+	// Documents from before the line counts were written hold whole files.
+	oldCount, newCount := header.Old.Lines, header.New.Lines
+	for n, line := range lines {
+		converted, err := diffLineOf(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n+1, err)
+		}
+		result.Lines = append(result.Lines, converted)
+		oldCount = max(oldCount, converted.LineNo1)
+		newCount = max(newCount, converted.LineNo2)
+	}
+
+	result.File1Lines, result.File2Lines = make([]string, oldCount), make([]string, newCount)
+	for _, line := range result.Lines {
+		if line.LineNo1 > 0 {
+			result.File1Lines[line.LineNo1-1] = line.Content
+		}
+		if line.LineNo2 > 0 {
+			result.File2Lines[line.LineNo2-1] = line.Content
+		}
+	}
+	return result, nil
+}
+
+func readNDJSONLines(data []byte) ([]jsonLine, error) {
+	var lines []jsonLine
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 64*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		var line jsonLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			return nil, fmt.Errorf("record %d: %w", n, err)
+		}
+		if line.Record == "line" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+func diffLineOf(line jsonLine) (diff.DiffLine, error) {
+	out := diff.DiffLine{Content: line.Content, LineNo1: line.OldLine, LineNo2: line.NewLine}
+	switch line.Type {
+	case "equal":
+		out.Type = diff.Equal
+	case "added":
+		out.Type = diff.Added
+	case "removed":
+		out.Type = diff.Removed
+	default:
+		return out, fmt.Errorf("unknown line type %q", line.Type)
+	}
+	for _, h := range line.Highlights {
+		out.Highlights = append(out.Highlights, diff.Highlight{Start: h.Start, End: h.End})
+	}
+	return out, nil
+}
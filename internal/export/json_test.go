@@ -0,0 +1,141 @@
+package export
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cj3636/gdiff/internal/difftest"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		binary   bool
+		lines    []LineRange
+		hunks    []int
+	}{
+		{name: "change with highlights", old: "x := 1\ny := 2\n", new: "x := 10\ny := 2\n"},
+		{name: "added and removed lines", old: difftest.Numbered(20), new: difftest.Numbered(20, 2, 18)},
+		{name: "old file lacks a final newline", old: "a\nb", new: "a\nb\nc\n"},
+		{name: "new file drops the final newline", old: "a\nb\n", new: "a\nb"},
+		{name: "new file", old: "", new: "x\ny\n"},
+		{name: "identical", old: "same\n", new: "same\n"},
+		{name: "binary", old: "a\n", new: "b\n", binary: true},
+		{name: "unicode and tabs", old: "\tgrüß\n", new: "\tgrüße 世界\n"},
+		{
+			name:  "last hunk of a file without a final newline",
+			old:   strings.TrimSuffix(difftest.Numbered(20), "\n"),
+			new:   difftest.Numbered(20, 2, 20),
+			hunks: []int{1},
+		},
+		{name: "first hunk", old: difftest.Numbered(20), new: difftest.Numbered(20, 2, 18), hunks: []int{0}},
+		{name: "lines from the middle", old: difftest.Numbered(20), new: difftest.Numbered(20, 10), lines: []LineRange{{Start: 8, End: 11}}},
+	}
+
+	for _, format := range []Format{FormatJSON, FormatNDJSON} {
+		for _, tt := range tests {
+			t.Run(string(format)+"/"+tt.name, func(t *testing.T) {
+				full := difftest.Texts(tt.old, tt.new)
+				full.Binary = tt.binary
+				opts := Options{Context: 3, Lines: tt.lines, Hunks: tt.hunks}
+
+				// A limited export holds the selected lines, numbered as in
+				// the whole files.
+				original, err := scopeResult(full, string(format), opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				encoded, err := Render(full, format, opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				loaded, err := ReadJSON(strings.NewReader(encoded))
+				if err != nil {
+					t.Fatalf("ReadJSON: %v", err)
+				}
+
+				if loaded.File1Name != original.File1Name || loaded.File2Name != original.File2Name ||
+					loaded.File1NoEOL != original.File1NoEOL || loaded.File2NoEOL != original.File2NoEOL ||
+					loaded.Binary != original.Binary {
+					t.Errorf("file details = %q %q %v %v %v, want %q %q %v %v %v",
+						loaded.File1Name, loaded.File2Name, loaded.File1NoEOL, loaded.File2NoEOL, loaded.Binary,
+						original.File1Name, original.File2Name, original.File1NoEOL, original.File2NoEOL, original.Binary)
+				}
+				if !reflect.DeepEqual(loaded.Lines, original.Lines) {
+					t.Errorf("lines = %+v, want %+v", loaded.Lines, original.Lines)
+				}
+				if len(loaded.File1Lines) != len(original.File1Lines) || len(loaded.File2Lines) != len(original.File2Lines) {
+					t.Errorf("files have %d and %d lines, want %d and %d",
+						len(loaded.File1Lines), len(loaded.File2Lines), len(original.File1Lines), len(original.File2Lines))
+				}
+
+				// Everything derived from the lines comes out the same.
+				for _, derived := range []Format{FormatPatch, FormatJSON} {
+					want, _ := Render(original, derived, Options{Context: 3})
+					got, _ := Render(loaded, derived, Options{Context: 3})
+					if got != want {
+						t.Errorf("%s of the loaded diff =\n%s\nwant\n%s", derived, got, want)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestReadJSONErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		err   string
+	}{
+		{name: "not json", input: "--- a/x\n+++ b/x\n", err: "not a gdiff JSON diff"},
+		{name: "other json", input: `{"name": "x"}`, err: "missing schema"},
+		{name: "newer version", input: `{"schema": "gdiff.diff", "version": 99}`, err: "newer than the supported version"},
+		{
+			name:  "unknown line type",
+			input: `{"schema": "gdiff.diff", "version": 1, "lines": [{"type": "moved", "content": "x"}]}`,
+			err:   `line 1: unknown line type "moved"`,
+		},
+		{
+			name:  "broken ndjson record",
+			input: `{"record": "diff", "schema": "gdiff.diff", "version": 1}` + "\n{\n",
+			err:   "record 2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadJSON(strings.NewReader(tt.input))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("ReadJSON() error = %v, want it to contain %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestWriteNDJSONScope(t *testing.T) {
+	result := difftest.Texts(difftest.Numbered(20), difftest.Numbered(20, 2, 18))
+	opts := Options{Context: 3, Hunks: []int{1}}
+
+	var streamed strings.Builder
+	if err := WriteNDJSON(&streamed, result, opts); err != nil {
+		t.Fatal(err)
+	}
+	rendered, err := Render(result, FormatNDJSON, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if streamed.String() != rendered {
+		t.Errorf("WriteNDJSON =\n%s\nwant the same as Render:\n%s", streamed.String(), rendered)
+	}
+
+	loaded, err := ReadJSON(strings.NewReader(rendered))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Lines) != 7 || loaded.Lines[0].LineNo1 != 15 {
+		t.Errorf("scoped export holds %d lines from old line %d, want 7 from 15", len(loaded.Lines), loaded.Lines[0].LineNo1)
+	}
+}
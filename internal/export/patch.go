@@ -0,0 +1,95 @@
+package export
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/cj3636/gdiff/internal/diff"
+)
+
+const noNewlineMarker = "\\ No newline at end of file\n"
+
+// renderPatch writes a unified diff. Within each run of changes removed lines
+// come before added ones, and the a/ and b/ prefixes make the output apply
+// with git apply or patch -p1 from the directory the paths are relative to.
+func renderPatch(result *diff.DiffResult, opts Options) string {
+	oldPath, newPath := patchPath(opts.OldPath, result.File1Name, "a/"), patchPath(opts.NewPath, result.File2Name, "b/")
+
+	var b strings.Builder
+	if result.Binary {
+		if result.HasChanges() {
+			fmt.Fprintf(&b, "Binary files %s and %s differ\n", oldPath, newPath)
+		}
+		return b.String()
+	}
+
+	hunks := result.Hunks(opts.Context)
+	if len(hunks) == 0 {
+		return ""
+	}
+
+	// Only paths named by the caller describe a rename; two arbitrary files
+	// compared outside a repository do not.
+	renamed := opts.OldPath != "" && opts.NewPath != "" && opts.OldPath != DevNull && opts.NewPath != DevNull
+	if renamed && oldPath[2:] != newPath[2:] {
+		fmt.Fprintf(&b, "diff --git %s %s\nrename from %s\nrename to %s\n", oldPath, newPath, oldPath[2:], newPath[2:])
+	}
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", oldPath, newPath)
+
+	for _, hunk := range hunks {
+		b.WriteString(result.HunkHeader(hunk))
+		b.WriteByte('\n')
+
+		var added []int
+		flushAdded := func() {
+			for _, i := range added {
+				writePatchLine(&b, result, "+", i)
+			}
+			added = added[:0]
+		}
+
+		for i := hunk.Start; i < hunk.End; i++ {
+			line := result.Lines[i]
+			switch {
+			case result.EOFChanged(i):
+				writePatchLine(&b, result, "-", i)
+				added = append(added, i)
+			case line.Type == diff.Removed:
+				writePatchLine(&b, result, "-", i)
+			case line.Type == diff.Added:
+				added = append(added, i)
+			default:
+				flushAdded()
+				writePatchLine(&b, result, " ", i)
+			}
+		}
+		flushAdded()
+	}
+	return b.String()
+}
+
+// writePatchLine writes one line of a hunk and, when it is the final line of
+// a file lacking a trailing newline, the marker saying so.
+func writePatchLine(b *strings.Builder, result *diff.DiffResult, symbol string, i int) {
+	line := result.Lines[i]
+	b.WriteString(symbol)
+	b.WriteString(line.Content)
+	b.WriteByte('\n')
+
+	lastOld := line.LineNo1 > 0 && line.LineNo1 == len(result.File1Lines) && result.File1NoEOL
+	lastNew := line.LineNo2 > 0 && line.LineNo2 == len(result.File2Lines) && result.File2NoEOL
+	if (symbol == "-" && lastOld) || (symbol == "+" && lastNew) || (symbol == " " && lastOld && lastNew) {
+		b.WriteString(noNewlineMarker)
+	}
+}
+
+func patchPath(path, fallback, prefix string) string {
+	if path == "" {
+		path = fallback
+	}
+	if path == DevNull {
+		return DevNull
+	}
+	return prefix + strings.TrimPrefix(filepath.ToSlash(filepath.Clean(path)), "/")
+}
@@ -0,0 +1,167 @@
+package export
+
+import (
+	"testing"
+
+	"github.com/cj3636/gdiff/internal/difftest"
+)
+
+func TestRenderPatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		opts     Options
+		expected string
+	}{
+		{
+			name: "identical files give an empty patch",
+			old:  "a\nb\n",
+			new:  "a\nb\n",
+			opts: Options{Context: 3},
+		},
+		{
+			name: "change in the middle",
+			old:  difftest.Numbered(10),
+			new:  difftest.Numbered(10, 5),
+			opts: Options{Context: 3},
+			expected: "--- a/old.txt\n+++ b/new.txt\n@@ -2,7 +2,7 @@\n" +
+				" 2\n 3\n 4\n-5\n+x5\n 6\n 7\n 8\n",
+		},
+		{
+			name:     "removed lines come before added ones",
+			old:      "a\nb\nc\n",
+			new:      "a\nB\nC\n",
+			opts:     Options{Context: 3},
+			expected: "--- a/old.txt\n+++ b/new.txt\n@@ -1,3 +1,3 @@\n a\n-b\n-c\n+B\n+C\n",
+		},
+		{
+			name:     "no context",
+			old:      difftest.Numbered(10),
+			new:      difftest.Numbered(10, 3, 8),
+			opts:     Options{Context: 0},
+			expected: "--- a/old.txt\n+++ b/new.txt\n@@ -3 +3 @@\n-3\n+x3\n@@ -8 +8 @@\n-8\n+x8\n",
+		},
+		{
+			name:     "new file",
+			old:      "",
+			new:      "x\ny\n",
+			opts:     Options{Context: 3, OldPath: DevNull, NewPath: "dir/new.txt"},
+			expected: "--- /dev/null\n+++ b/dir/new.txt\n@@ -0,0 +1,2 @@\n+x\n+y\n",
+		},
+		{
+			name:     "deleted file",
+			old:      "x\ny\n",
+			new:      "",
+			opts:     Options{Context: 3, OldPath: "dir/old.txt", NewPath: DevNull},
+			expected: "--- a/dir/old.txt\n+++ /dev/null\n@@ -1,2 +0,0 @@\n-x\n-y\n",
+		},
+		{
+			name: "rename",
+			old:  "a\n",
+			new:  "b\n",
+			opts: Options{Context: 3, OldPath: "src/a.txt", NewPath: "src/b.txt"},
+			expected: "diff --git a/src/a.txt b/src/b.txt\nrename from src/a.txt\nrename to src/b.txt\n" +
+				"--- a/src/a.txt\n+++ b/src/b.txt\n@@ -1 +1 @@\n-a\n+b\n",
+		},
+		{
+			name: "old file lacks a final newline",
+			old:  "a\nb",
+			new:  "a\nb\nc\n",
+			opts: Options{Context: 3},
+			expected: "--- a/old.txt\n+++ b/new.txt\n@@ -1,2 +1,3 @@\n a\n-b\n" + noNewlineMarker +
+				"+b\n+c\n",
+		},
+		{
+			name:     "new file drops the final newline",
+			old:      "a\nb\n",
+			new:      "a\nb",
+			opts:     Options{Context: 3},
+			expected: "--- a/old.txt\n+++ b/new.txt\n@@ -1,2 +1,2 @@\n a\n-b\n+b\n" + noNewlineMarker,
+		},
+		{
+			name: "both lack a final newline and the last line changes",
+			old:  "a\nb",
+			new:  "a\nc",
+			opts: Options{Context: 3},
+			expected: "--- a/old.txt\n+++ b/new.txt\n@@ -1,2 +1,2 @@\n a\n-b\n" + noNewlineMarker +
+				"+c\n" + noNewlineMarker,
+		},
+		{
+			name:     "both lack a final newline on shared context",
+			old:      "a\nb",
+			new:      "A\nb",
+			opts:     Options{Context: 3},
+			expected: "--- a/old.txt\n+++ b/new.txt\n@@ -1,2 +1,2 @@\n-a\n+A\n b\n" + noNewlineMarker,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Render(difftest.Texts(tt.old, tt.new), FormatPatch, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.expected {
+				t.Errorf("Render(patch) =\n%s\nwant\n%s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRenderPatchBinary(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		expected string
+	}{
+		{name: "changed", old: "a\n", new: "b\n", expected: "Binary files a/old.txt and b/new.txt differ\n"},
+		{name: "unchanged", old: "a\n", new: "a\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := difftest.Texts(tt.old, tt.new)
+			result.Binary = true
+			got, err := Render(result, FormatPatch, Options{Context: 3})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.expected {
+				t.Errorf("Render(patch) = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRenderPatchHunkScope(t *testing.T) {
+	result := difftest.Texts(difftest.Numbered(20), difftest.Numbered(20, 2, 18))
+
+	tests := []struct {
+		name     string
+		hunks    []int
+		expected string
+	}{
+		{
+			name:     "first hunk",
+			hunks:    []int{0},
+			expected: "--- a/old.txt\n+++ b/new.txt\n@@ -1,5 +1,5 @@\n 1\n-2\n+x2\n 3\n 4\n 5\n",
+		},
+		{
+			name:     "second hunk applies to the old file",
+			hunks:    []int{1},
+			expected: "--- a/old.txt\n+++ b/new.txt\n@@ -15,6 +15,6 @@\n 15\n 16\n 17\n-18\n+x18\n 19\n 20\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Render(result, FormatPatch, Options{Context: 3, Hunks: tt.hunks})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.expected {
+				t.Errorf("Render(patch) =\n%s\nwant\n%s", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package export
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/cj3636/gdiff/internal/diff"
+)
+
+// redactMask replaces each character of a secret, so line lengths and
+// highlight offsets stay as they were.
+const redactMask = "*"
+
+// builtinSecretPatterns detect common credential formats. When a pattern has
+// a capture group only the group is masked, keeping the key name readable.
+var builtinSecretPatterns = []string{
+	`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`,                                  // AWS access key ID
+	`(?i)aws.{0,20}?secret.{0,20}?["'=:\s]([A-Za-z0-9/+]{40})\b`,     // AWS secret access key
+	`\bgh[pousr]_[A-Za-z0-9]{36,}\b`,                                 // GitHub token
+	`\bgithub_pat_[A-Za-z0-9_]{22,}\b`,                               // GitHub fine-grained token
+	`\bglpat-[A-Za-z0-9_-]{20,}\b`,                                   // GitLab token
+	`\bxox[abposr]-[A-Za-z0-9-]{10,}\b`,                              // Slack token
+	`\b[sr]k_live_[A-Za-z0-9]{16,}\b`,                                // Stripe key
+	`\bAIza[0-9A-Za-z_-]{35}\b`,                                      // Google API key
+	`\beyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}\b`, // JSON web token
+	`://[^/\s:@]+:([^/\s@]+)@`,                                       // password in a URL
+	// A quoted literal assigned to a credential-like name. Unquoted values
+	// are left alone, as they are usually code such as token := lexer.Next().
+	`(?i)(?:password|passwd|pwd|secret|token|api[_-]?key|access[_-]?key|auth)["']?\s*(?::=|[:=])\s*["']([^\s"']{4,})["']`,
+}
+
+var (
+	privateKeyBegin = regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY( BLOCK)?-----`)
+	privateKeyEnd   = regexp.MustCompile(`-----END [A-Z ]*PRIVATE KEY( BLOCK)?-----`)
+)
+
+// Redactor masks secrets in diffs before they are exported or copied.
+type Redactor struct {
+	patterns    []*regexp.Regexp
+	privateKeys bool
+}
+
+// NewRedactor compiles the user patterns, plus the built-in detectors when
+// builtin is set. It returns nil when there is nothing to detect.
+func NewRedactor(patterns []string, builtin bool) (*Redactor, error) {
+	r := &Redactor{privateKeys: builtin}
+	sources := patterns
+	if builtin {
+		sources = append(append([]string{}, builtinSecretPatterns...), patterns...)
+	}
+	for _, source := range sources {
+		re, err := regexp.Compile(source)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", source, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	if len(r.patterns) == 0 && !r.privateKeys {
+		return nil, nil
+	}
+	return r, nil
+}
+
+// Redact masks the secrets in one line and reports how many it found.
+func (r *Redactor) Redact(line string) (string, int) {
+	if r == nil {
+		return line, 0
+	}
+
+	count := 0
+	for _, re := range r.patterns {
+		matches := re.FindAllStringSubmatchIndex(line, -1)
+		if len(matches) == 0 {
+			continue
+		}
+
+		var b strings.Builder
+		last := 0
+		for _, m := range matches {
+			start, end := m[0], m[1]
+			if len(m) >= 4 && m[2] >= 0 {
+				start, end = m[2], m[3]
+			}
+			// Skip secrets an earlier pattern has already masked.
+			if start < last || start == end || strings.Trim(line[start:end], redactMask) == "" {
+				continue
+			}
+			b.WriteString(line[last:start])
+			b.WriteString(maskOf(line[start:end]))
+			last = end
+			count++
+		}
+		b.WriteString(line[last:])
+		line = b.String()
+	}
+	return line, count
+}
+
+// Result returns a copy of result with secrets masked in every line, and the
+// number of secrets masked. Lines inside a PEM private key block are masked
+// whole. The result itself is returned when nothing was found.
+func (r *Redactor) Result(result *diff.DiffResult) (*diff.DiffResult, int) {
+	if r == nil || result == nil {
+		return result, 0
+	}
+
+	lines := make([]diff.DiffLine, len(result.Lines))
+	total := 0
+	var inOldKey, inNewKey bool
+	for i, line := range result.Lines {
+		inKey := line.LineNo1 > 0 && inOldKey || line.LineNo2 > 0 && inNewKey
+		begins := r.privateKeys && privateKeyBegin.MatchString(line.Content)
+		ends := r.privateKeys && privateKeyEnd.MatchString(line.Content)
+
+		if inKey && !ends {
+			line.Content = maskOf(line.Content)
+		} else {
+			var n int
+			line.Content, n = r.Redact(line.Content)
+			total += n
+		}
+		if begins {
+			total++
+		}
+		lines[i] = line
+
+		if begins || ends {
+			if line.LineNo1 > 0 {
+				inOldKey = begins && !ends
+			}
+			if line.LineNo2 > 0 {
+				inNewKey = begins && !ends
+			}
+		}
+	}
+	if total == 0 {
+		return result, 0
+	}
+
+	redacted := *result
+	redacted.Lines = lines
+	redacted.File1Lines, redacted.File2Lines = nil, nil
+	for _, line := range lines {
+		if line.LineNo1 > 0 {
+			redacted.File1Lines = append(redacted.File1Lines, line.Content)
+		}
+		if line.LineNo2 > 0 {
+			redacted.File2Lines = append(redacted.File2Lines, line.Content)
+		}
+	}
+	return &redacted, total
+}
+
+// ResultFor masks secrets in result for an export in format. Edit scripts,
+// patches and the normal, context and ed formats, have to reproduce the
+// files exactly, so a diff with secrets in it is refused for them instead of
+// exported in a form that no longer applies.
+func (r *Redactor) ResultFor(result *diff.DiffResult, format Format) (*diff.DiffResult, int, error) {
+	redacted, count := r.Result(result)
+	if count > 0 && editFormat(strings.ToLower(string(format))) {
+		return nil, 0, fmt.Errorf("the diff contains %d secrets, and a %s export with them masked would not apply; export it with --no-redact or use another format", count, format)
+	}
+	return redacted, count, nil
+}
+
+func maskOf(secret string) string {
+	return strings.Repeat(redactMask, len([]rune(secret)))
+}
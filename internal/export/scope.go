@@ -0,0 +1,124 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cj3636/gdiff/internal/diff"
+)
+
+// LineRange is a span of DiffResult.Lines indexes, End exclusive.
+type LineRange struct {
+	Start int
+	End   int
+}
+
+// scoped reports whether the options limit the export to part of the diff.
+func (o Options) scoped() bool {
+	return o.Lines != nil || o.Hunks != nil
+}
+
+// editFormat reports whether a format describes edits to the old file, so a
+// part of the diff has to be exported as a smaller edit rather than as an
+// excerpt.
+func editFormat(name string) bool {
+	switch name {
+	case string(FormatPatch), "diff", "unified", string(FormatNormal), string(FormatContext), string(FormatEd):
+		return true
+	default:
+		return false
+	}
+}
+
+// selectedLines marks the Lines indexes inside opts.Lines and opts.Hunks.
+func selectedLines(result *diff.DiffResult, opts Options) ([]bool, error) {
+	selected := make([]bool, len(result.Lines))
+	mark := func(start, end int) {
+		for i := max(start, 0); i < min(end, len(selected)); i++ {
+			selected[i] = true
+		}
+	}
+
+	for _, r := range opts.Lines {
+		if r.Start >= len(result.Lines) || r.End <= r.Start {
+			return nil, fmt.Errorf("line range %d-%d is outside the diff", r.Start+1, r.End)
+		}
+		mark(r.Start, r.End)
+	}
+	if opts.Hunks != nil {
+		hunks := result.Hunks(opts.Context)
+		for _, h := range opts.Hunks {
+			if h < 0 || h >= len(hunks) {
+				return nil, fmt.Errorf("hunk %d does not exist; the diff has %d", h+1, len(hunks))
+			}
+			mark(hunks[h].Start, hunks[h].End)
+		}
+	}
+	return selected, nil
+}
+
+// scopeResult narrows result to the lines opts select. Edit formats get the
+// whole diff with the unselected changes undone: removed lines stay as
+// context and added lines are dropped, so a patch made from it applies to
+// the old file and makes only the selected changes. Other formats get just
+// the selected lines, numbered as in the full diff.
+func scopeResult(result *diff.DiffResult, name string, opts Options) (*diff.DiffResult, error) {
+	if !opts.scoped() || result.Binary {
+		return result, nil
+	}
+	selected, err := selectedLines(result, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	scoped := *result
+	scoped.Lines = nil
+	if !editFormat(name) {
+		for i, line := range result.Lines {
+			if selected[i] {
+				scoped.Lines = append(scoped.Lines, line)
+			}
+		}
+		if len(scoped.Lines) == 0 {
+			return nil, errors.New("nothing is selected")
+		}
+		return &scoped, nil
+	}
+
+	scoped.File2Lines = nil
+	scoped.File2NoEOL = false
+	for i, line := range result.Lines {
+		if !selected[i] {
+			switch line.Type {
+			case diff.Added:
+				continue
+			case diff.Removed:
+				line.Type = diff.Equal
+				line.Highlights = nil
+			}
+		}
+
+		if line.Type != diff.Removed {
+			// A selected line ends as it does in the new file, so a line
+			// that only gains or loses its newline can be picked alone;
+			// any other line ends as it does in the old one.
+			if selected[i] && line.LineNo2 > 0 {
+				scoped.File2NoEOL = line.LineNo2 == len(result.File2Lines) && result.File2NoEOL
+			} else {
+				scoped.File2NoEOL = line.LineNo1 == len(result.File1Lines) && result.File1NoEOL
+			}
+			scoped.File2Lines = append(scoped.File2Lines, line.Content)
+			line.LineNo2 = len(scoped.File2Lines)
+		}
+		scoped.Lines = append(scoped.Lines, line)
+	}
+	// A selected change can vanish with its neighbours: a line that only
+	// loses its newline by coming last no longer does once the lines after
+	// it are kept.
+	for i := range scoped.Lines {
+		if scoped.LineChanged(i) {
+			return &scoped, nil
+		}
+	}
+	return nil, errors.New("the selection contains no changes")
+}
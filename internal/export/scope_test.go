@@ -0,0 +1,121 @@
+package export
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cj3636/gdiff/internal/diff"
+	"github.com/cj3636/gdiff/internal/difftest"
+)
+
+// gitApply applies patch to a file holding old with git apply, after
+// checking it with git apply --check, and returns the patched content.
+func gitApply(t *testing.T, old, patch string) string {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "f.txt")
+	if err := os.WriteFile(path, []byte(old), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{{"apply", "--check"}, {"apply"}} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Stdin = strings.NewReader(patch)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v\n%s\npatch:\n%s", strings.Join(args, " "), err, out, patch)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestScopedPatchApplies(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+
+	tests := []struct {
+		name     string
+		old, new string
+		lines    []LineRange
+		hunks    []int
+		expected string // the old file with the selected changes made
+	}{
+		{name: "whole diff", old: difftest.Numbered(20), new: difftest.Numbered(20, 2, 18), lines: []LineRange{{Start: 0, End: 22}},
+			expected: difftest.Numbered(20, 2, 18)},
+		{name: "first hunk", old: difftest.Numbered(20), new: difftest.Numbered(20, 2, 18), hunks: []int{0},
+			expected: difftest.Numbered(20, 2)},
+		{name: "second hunk", old: difftest.Numbered(20), new: difftest.Numbered(20, 2, 18), hunks: []int{1},
+			expected: difftest.Numbered(20, 18)},
+		{name: "only the removed line", old: "a\nb\nc\n", new: "a\nB\nc\n", lines: []LineRange{{Start: 1, End: 2}},
+			expected: "a\nc\n"},
+		{name: "only the added line", old: "a\nb\nc\n", new: "a\nB\nc\n", lines: []LineRange{{Start: 2, End: 3}},
+			expected: "a\nb\nB\nc\n"},
+		{name: "one of several added lines", old: "a\nd\n", new: "a\nb\nc\nd\n", lines: []LineRange{{Start: 2, End: 3}},
+			expected: "a\nc\nd\n"},
+		{name: "a line added after a missing newline", old: "a\nb", new: "a\nb\nc\n", lines: []LineRange{{Start: 2, End: 3}},
+			expected: "a\nb\nc\n"},
+		{name: "only the missing newline", old: "a\nb", new: "a\nb\nc\n", lines: []LineRange{{Start: 1, End: 2}},
+			expected: "a\nb\n"},
+		{name: "dropping the final newline", old: "a\nb\n", new: "a\nb", hunks: []int{0}, expected: "a\nb"},
+		{name: "last line changed without newlines", old: "a\nb", new: "a\nc", lines: []LineRange{{Start: 2, End: 3}},
+			expected: "a\nb\nc"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := difftest.Texts(tt.old, tt.new)
+			patch, err := Render(result, FormatPatch, Options{Context: 3, Lines: tt.lines, Hunks: tt.hunks, OldPath: "f.txt", NewPath: "f.txt"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := gitApply(t, tt.old, patch); got != tt.expected {
+				t.Errorf("patched file = %q, want %q\npatch:\n%s", got, tt.expected, patch)
+			}
+		})
+	}
+}
+
+func TestScopeResultErrors(t *testing.T) {
+	result := difftest.Texts(difftest.Numbered(20), difftest.Numbered(20, 2, 18))
+	// The new file's last line "a" loses its newline, which it keeps when
+	// the removed lines after it stay.
+	newlineOnly := difftest.Texts("a\na\nd\nb\n", "d\nd\na")
+
+	tests := []struct {
+		name   string
+		result *diff.DiffResult
+		format Format
+		opts   Options
+		err    string
+	}{
+		{name: "context only", format: FormatPatch, opts: Options{Context: 3, Lines: []LineRange{{Start: 5, End: 8}}},
+			err: "the selection contains no changes"},
+		{name: "missing hunk", format: FormatPatch, opts: Options{Context: 3, Hunks: []int{2}},
+			err: "hunk 3 does not exist; the diff has 2"},
+		{name: "lines past the end", format: FormatJSON, opts: Options{Lines: []LineRange{{Start: 40, End: 41}}},
+			err: "line range 41-41 is outside the diff"},
+		{name: "empty range", format: FormatPatch, opts: Options{Lines: []LineRange{{Start: 3, End: 3}}},
+			err: "line range 4-3 is outside the diff"},
+		{name: "a newline change that no longer applies", result: newlineOnly, format: FormatPatch,
+			opts: Options{Context: 3, Lines: []LineRange{{Start: 2, End: 3}}}, err: "the selection contains no changes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.result == nil {
+				tt.result = result
+			}
+			_, err := Render(tt.result, tt.format, tt.opts)
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("Render() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
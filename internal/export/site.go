@@ -0,0 +1,132 @@
+package export
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cj3636/gdiff/internal/diff"
+)
+
+// SiteFile is one changed file of a multi-page HTML export.
+type SiteFile struct {
+	Path    string
+	OldPath string // the path before a rename, if the file was renamed
+	Status  string // added, deleted, renamed or modified
+	Result  *diff.DiffResult
+}
+
+// Stat counts the changes to the file.
+func (f SiteFile) Stat() FileStat {
+	stat := StatOf(f.Path, f.Result)
+	stat.OldPath = f.OldPath
+	return stat
+}
+
+// WriteSite writes a static site to dir: index.html with a diffstat table
+// that links to a page per file under files/, and the style.css they share.
+// Nothing is loaded from outside dir, so the folder can be published or
+// opened as is.
+func WriteSite(dir, title string, files []SiteFile, opts Options) error {
+	if err := os.MkdirAll(filepath.Join(dir, "files"), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "style.css"), []byte(htmlStyles(opts.Theme)+siteStyles), 0o644); err != nil {
+		return err
+	}
+
+	pages := make([]string, len(files))
+	for i, file := range files {
+		pages[i] = "files/" + sitePageName(i, file.Path)
+		if err := os.WriteFile(filepath.Join(dir, filepath.FromSlash(pages[i])), []byte(renderSitePage(file, opts)), 0o644); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(filepath.Join(dir, "index.html"), []byte(renderSiteIndex(title, files, pages)), 0o644)
+}
+
+// sitePageName numbers pages so files whose paths flatten to the same name
+// stay apart.
+func sitePageName(i int, path string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, filepath.ToSlash(path))
+	return fmt.Sprintf("%03d-%s.html", i+1, name)
+}
+
+func renderSitePage(file SiteFile, opts Options) string {
+	opts.Title = file.Path
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n<html><head><meta charset=\"utf-8\">")
+	fmt.Fprintf(&b, "<title>%s</title>\n<link rel=\"stylesheet\" href=\"../style.css\"></head><body>\n", html.EscapeString(file.Path))
+	b.WriteString("<nav><a href=\"../index.html\">← All files</a></nav>\n")
+	writeHTMLDiff(&b, file.Result, file.Path, opts)
+	b.WriteString("</body></html>\n")
+	return b.String()
+}
+
+func renderSiteIndex(title string, files []SiteFile, pages []string) string {
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n<html><head><meta charset=\"utf-8\">")
+	fmt.Fprintf(&b, "<title>%s</title>\n<link rel=\"stylesheet\" href=\"style.css\"></head><body>\n", html.EscapeString(title))
+	fmt.Fprintf(&b, "<h1>%s</h1>\n", html.EscapeString(title))
+
+	totalAdded, totalRemoved, most := 0, 0, 1
+	counts := make([][2]int, len(files))
+	for i, file := range files {
+		added, removed, _ := file.Result.GetStats()
+		counts[i] = [2]int{added, removed}
+		totalAdded += added
+		totalRemoved += removed
+		most = max(most, added+removed)
+	}
+	fmt.Fprintf(&b, "<p class=\"stats\">%d files changed, <span class=\"added\">+%d</span> <span class=\"removed\">-%d</span></p>\n",
+		len(files), totalAdded, totalRemoved)
+
+	b.WriteString("<p class=\"filters\"><input id=\"filter\" type=\"search\" placeholder=\"Filter files\"> <select id=\"status\">" +
+		"<option value=\"\">All changes</option><option>added</option><option>deleted</option><option>renamed</option><option>modified</option></select></p>\n")
+	b.WriteString("<table class=\"files\">\n<thead><tr><th>Status</th><th>File</th><th>Added</th><th>Removed</th><th></th></tr></thead>\n<tbody>\n")
+	for i, file := range files {
+		added, removed := counts[i][0], counts[i][1]
+		name := file.Stat().Name()
+		fmt.Fprintf(&b, "<tr data-path=\"%s\" data-status=\"%s\"><td class=\"status %s\">%s</td><td><a href=\"%s\">%s</a></td>",
+			html.EscapeString(strings.ToLower(name)), file.Status, file.Status, file.Status, pages[i], html.EscapeString(name))
+		fmt.Fprintf(&b, "<td class=\"added\">+%d</td><td class=\"removed\">-%d</td>", added, removed)
+		fmt.Fprintf(&b, "<td class=\"bar\"><span class=\"added\" style=\"width:%d%%\"></span><span class=\"removed\" style=\"width:%d%%\"></span></td></tr>\n",
+			added*100/most, removed*100/most)
+	}
+	b.WriteString("</tbody>\n</table>\n")
+	b.WriteString(siteScript)
+	b.WriteString("</body></html>\n")
+	return b.String()
+}
+
+const siteStyles = "nav{padding:6px 12px;}a{color:var(--title-fg);}" +
+	".filters{margin:8px 12px;}.filters input,.filters select{background:color-mix(in srgb,var(--bg) 92%,var(--fg));color:var(--fg);border:1px solid var(--border);padding:4px 6px;font:inherit;}" +
+	".files{border-collapse:collapse;margin:0 12px;}.files th,.files td{padding:3px 10px;text-align:left;border-bottom:1px solid var(--border);}" +
+	".files th{color:var(--muted);font-weight:normal;}.files td.added{color:var(--added-fg);}.files td.removed{color:var(--removed-fg);}" +
+	".status{color:var(--muted);}.status.added{color:var(--added-fg);}.status.deleted{color:var(--removed-fg);}" +
+	".bar{width:120px;}.bar span{display:inline-block;height:8px;}.bar .added{background:var(--added-fg);}.bar .removed{background:var(--removed-fg);}"
+
+const siteScript = `<script>
+(function () {
+  var filter = document.getElementById("filter"), status = document.getElementById("status");
+  function apply() {
+    var text = filter.value.toLowerCase();
+    document.querySelectorAll(".files tbody tr").forEach(function (row) {
+      var show = row.dataset.path.indexOf(text) >= 0 && (!status.value || row.dataset.status === status.value);
+      row.style.display = show ? "" : "none";
+    });
+  }
+  filter.addEventListener("input", apply);
+  status.addEventListener("change", apply);
+})();
+</script>
+`
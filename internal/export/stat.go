@@ -0,0 +1,254 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/cj3636/gdiff/internal/diff"
+)
+
+// StatWidth is the width --stat output fits its graph into, as git does when
+// not writing to a terminal.
+const StatWidth = 80
+
+// FileStat counts the changes to one file.
+type FileStat struct {
+	Path      string
+	OldPath   string // the path before a rename, if the file was renamed
+	Added     int
+	Removed   int
+	Unchanged int
+	Hunks     int
+	Binary    bool
+}
+
+// StatOf counts the changes in result. A shared last line where only one
+// side ends without a newline counts as removed and added, as a patch shows
+// it; binary files count no lines.
+func StatOf(path string, result *diff.DiffResult) FileStat {
+	stat := FileStat{Path: path, Binary: result.Binary}
+	hunks := result.Hunks(0)
+	stat.Hunks = len(hunks)
+	if result.Binary {
+		return stat
+	}
+
+	stat.Added, stat.Removed, stat.Unchanged = result.GetStats()
+	for _, h := range hunks {
+		for i := h.Start; i < h.End; i++ {
+			if result.EOFChanged(i) {
+				stat.Added++
+				stat.Removed++
+				stat.Unchanged--
+			}
+		}
+	}
+	return stat
+}
+
+// Changed reports whether the file has any changes, a rename counting as
+// one.
+func (s FileStat) Changed() bool {
+	return s.Hunks > 0 || s.renamed()
+}
+
+func (s FileStat) renamed() bool {
+	return s.OldPath != "" && s.OldPath != s.Path
+}
+
+// Name is the file as diffstats list it: its path, or for a rename both
+// paths in git's "old => new" form.
+func (s FileStat) Name() string {
+	if !s.renamed() {
+		return s.Path
+	}
+	return renameName(s.OldPath, s.Path)
+}
+
+// renameName shortens a rename as git does, keeping the leading directories
+// and trailing path both paths share outside braces: "a/{b => c}/d.txt".
+// Without any in common it is just "old => new".
+func renameName(old, path string) string {
+	prefix := 0
+	for i := 0; i < len(old) && i < len(path) && old[i] == path[i]; i++ {
+		if old[i] == '/' {
+			prefix = i + 1
+		}
+	}
+
+	// The common suffix starts at a slash, which may be the one closing the
+	// prefix: "a/b.txt" and "a/c/b.txt" give "a/{ => c}/b.txt".
+	suffix := 0
+	floor := prefix
+	if prefix > 0 {
+		floor--
+	}
+	for i, j := len(old)-1, len(path)-1; i >= floor && j >= floor && old[i] == path[j]; i, j = i-1, j-1 {
+		if old[i] == '/' {
+			suffix = len(old) - i
+		}
+	}
+
+	oldMid, newMid := max(0, len(old)-prefix-suffix), max(0, len(path)-prefix-suffix)
+	if prefix+suffix == 0 {
+		return old + " => " + path
+	}
+	return old[:prefix] + "{" + old[prefix:prefix+oldMid] + " => " + path[prefix:prefix+newMid] + "}" + old[len(old)-suffix:]
+}
+
+// ChangedPercent is the share of the lines in both versions of the file that
+// were added or removed: 100 for a new or deleted file, and near 0 when a
+// line changed in a large one.
+func (s FileStat) ChangedPercent() float64 {
+	total := s.Added + s.Removed + 2*s.Unchanged
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Added+s.Removed) * 100 / float64(total)
+}
+
+// StatLimits are per-file change thresholds. A negative limit is not
+// checked.
+type StatLimits struct {
+	MaxAdded          int
+	MaxRemoved        int
+	MaxChangedPercent float64
+}
+
+// Breaches describes each limit that stat exceeds.
+func (l StatLimits) Breaches(stat FileStat) []string {
+	var breaches []string
+	if l.MaxAdded >= 0 && stat.Added > l.MaxAdded {
+		breaches = append(breaches, fmt.Sprintf("%d lines added, over the limit of %d", stat.Added, l.MaxAdded))
+	}
+	if l.MaxRemoved >= 0 && stat.Removed > l.MaxRemoved {
+		breaches = append(breaches, fmt.Sprintf("%d lines removed, over the limit of %d", stat.Removed, l.MaxRemoved))
+	}
+	if percent := stat.ChangedPercent(); l.MaxChangedPercent >= 0 && percent > l.MaxChangedPercent {
+		breaches = append(breaches, fmt.Sprintf("%s%% of lines changed, over the limit of %s%%",
+			strconv.FormatFloat(percent, 'f', 1, 64), strconv.FormatFloat(l.MaxChangedPercent, 'f', -1, 64)))
+	}
+	return breaches
+}
+
+// WriteNumstat writes a line of added and removed counts per changed file,
+// as git diff --numstat does, with dashes for binary files.
+func WriteNumstat(w io.Writer, stats []FileStat) error {
+	for _, stat := range stats {
+		if !stat.Changed() {
+			continue
+		}
+		var err error
+		if stat.Binary {
+			_, err = fmt.Fprintf(w, "-\t-\t%s\n", stat.Name())
+		} else {
+			_, err = fmt.Fprintf(w, "%d\t%d\t%s\n", stat.Added, stat.Removed, stat.Name())
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteStat writes a diffstat as git diff --stat does: a line per changed
+// file with its change count and a graph of pluses and minuses scaled to
+// fit width, then the summary line.
+func WriteStat(w io.Writer, stats []FileStat, width int) error {
+	nameWidth, countWidth, most := 0, 1, 0
+	for _, stat := range stats {
+		if !stat.Changed() {
+			continue
+		}
+		nameWidth = max(nameWidth, len([]rune(stat.Name())))
+		countWidth = max(countWidth, len(strconv.Itoa(stat.Added+stat.Removed)))
+		most = max(most, stat.Added+stat.Removed)
+	}
+	// Besides the graph a line holds the name, the count, " ", " | ", " "
+	// and an empty last column.
+	graphWidth := max(6, width-nameWidth-countWidth-6)
+
+	var b strings.Builder
+	for _, stat := range stats {
+		if !stat.Changed() {
+			continue
+		}
+		name := stat.Name()
+		fmt.Fprintf(&b, " %s%s | ", name, strings.Repeat(" ", nameWidth-len([]rune(name))))
+		if stat.Binary {
+			b.WriteString("Bin\n")
+			continue
+		}
+
+		added, removed := stat.Added, stat.Removed
+		if most > graphWidth {
+			// As git does, the smaller side is scaled and the larger one
+			// takes the rest, keeping a column for each.
+			total := scaleStat(added+removed, graphWidth, most)
+			if total < 2 && added > 0 && removed > 0 {
+				total = 2
+			}
+			if added < removed {
+				added = scaleStat(added, graphWidth, most)
+				removed = total - added
+			} else {
+				removed = scaleStat(removed, graphWidth, most)
+				added = total - removed
+			}
+		}
+		fmt.Fprintf(&b, "%*d", countWidth, stat.Added+stat.Removed)
+		if added+removed > 0 {
+			b.WriteString(" " + strings.Repeat("+", added) + strings.Repeat("-", removed))
+		}
+		b.WriteByte('\n')
+	}
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return err
+	}
+	return WriteShortstat(w, stats)
+}
+
+// scaleStat shrinks a change count to the graph, keeping at least one
+// column for any change.
+func scaleStat(n, width, most int) int {
+	if n == 0 {
+		return 0
+	}
+	return 1 + n*(width-1)/most
+}
+
+// WriteShortstat writes the summary line of a diffstat, as git diff
+// --shortstat does.
+func WriteShortstat(w io.Writer, stats []FileStat) error {
+	files, added, removed := 0, 0, 0
+	for _, stat := range stats {
+		if stat.Changed() {
+			files++
+			added += stat.Added
+			removed += stat.Removed
+		}
+	}
+
+	if files == 0 {
+		return nil
+	}
+
+	line := fmt.Sprintf(" %d %s changed", files, plural(files, "file", "files"))
+	if added > 0 || removed == 0 {
+		line += fmt.Sprintf(", %d %s(+)", added, plural(added, "insertion", "insertions"))
+	}
+	if removed > 0 || added == 0 {
+		line += fmt.Sprintf(", %d %s(-)", removed, plural(removed, "deletion", "deletions"))
+	}
+	_, err := io.WriteString(w, line+"\n")
+	return err
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
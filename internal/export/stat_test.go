@@ -0,0 +1,239 @@
+package export
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/cj3636/gdiff/internal/difftest"
+)
+
+// seqLines returns the lines first to last, as seq does.
+func seqLines(first, last int) string {
+	var b strings.Builder
+	for i := first; i <= last; i++ {
+		fmt.Fprintf(&b, "%d\n", i)
+	}
+	return b.String()
+}
+
+func statOf(path, old, new string, binary bool) FileStat {
+	result := difftest.Texts(old, new)
+	result.Binary = binary
+	return StatOf(path, result)
+}
+
+func TestStatOf(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		binary   bool
+		expected FileStat
+	}{
+		{name: "unchanged", old: "a\nb\n", new: "a\nb\n", expected: FileStat{Unchanged: 2}},
+		{name: "one line changed", old: difftest.Numbered(10), new: difftest.Numbered(10, 5), expected: FileStat{Added: 1, Removed: 1, Unchanged: 9, Hunks: 1}},
+		{name: "new file", old: "", new: "x\ny\n", expected: FileStat{Added: 2, Hunks: 1}},
+		{name: "old file lacks a final newline", old: "a\nb", new: "a\nb\nc\n", expected: FileStat{Added: 2, Removed: 1, Unchanged: 1, Hunks: 1}},
+		{name: "only the final newline changes", old: "a\nb\n", new: "a\nb", expected: FileStat{Added: 1, Removed: 1, Unchanged: 1, Hunks: 1}},
+		{name: "binary", old: "a\n", new: "b\n", binary: true, expected: FileStat{Binary: true, Hunks: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.expected.Path = "f"
+			if got := statOf("f", tt.old, tt.new, tt.binary); got != tt.expected {
+				t.Errorf("StatOf() = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+// gitStats are the files of a change whose expected output below is that of
+// git diff --no-renames with --stat=80, --numstat and --shortstat.
+func gitStats() []FileStat {
+	return []FileStat{
+		statOf("added-file-with-a-long-name.txt", "", seqLines(1, 3), false),
+		statOf("gone.txt", seqLines(1, 5), "", false),
+		statOf("img.bin", "\x00\x01", "\x00\x02", true),
+		statOf("mid.txt", seqLines(1, 50), seqLines(21, 250), false),
+		statOf("noeol.txt", "a\nb", "a\nb\nc\n", false),
+		statOf("same.txt", "a\n", "a\n", false),
+		statOf("small.txt", seqLines(1, 10), strings.Replace(seqLines(1, 10), "5\n", "five\n", 1), false),
+	}
+}
+
+func TestWriteStat(t *testing.T) {
+	// git also gives binary sizes: "Bin 2 -> 2 bytes".
+	expected := " added-file-with-a-long-name.txt |   3 +\n" +
+		" gone.txt                        |   5 -\n" +
+		" img.bin                         | Bin\n" +
+		" mid.txt                         | 220 ++++++++++++++++++++++++++++++++++++----\n" +
+		" noeol.txt                       |   3 +-\n" +
+		" small.txt                       |   2 +-\n" +
+		" 6 files changed, 206 insertions(+), 27 deletions(-)\n"
+
+	var b strings.Builder
+	if err := WriteStat(&b, gitStats(), StatWidth); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != expected {
+		t.Errorf("WriteStat() =\n%s\nwant\n%s", b.String(), expected)
+	}
+}
+
+func TestWriteNumstat(t *testing.T) {
+	expected := "3\t0\tadded-file-with-a-long-name.txt\n" +
+		"0\t5\tgone.txt\n" +
+		"-\t-\timg.bin\n" +
+		"200\t20\tmid.txt\n" +
+		"2\t1\tnoeol.txt\n" +
+		"1\t1\tsmall.txt\n"
+
+	var b strings.Builder
+	if err := WriteNumstat(&b, gitStats()); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != expected {
+		t.Errorf("WriteNumstat() =\n%s\nwant\n%s", b.String(), expected)
+	}
+}
+
+func TestRenameName(t *testing.T) {
+	// Expected names are those of git diff --stat -M.
+	tests := []struct {
+		old, new string
+		expected string
+	}{
+		{old: "f.txt", new: "h.txt", expected: "f.txt => h.txt"},
+		{old: "dir/a.txt", new: "dir/b.txt", expected: "dir/{a.txt => b.txt}"},
+		{old: "src/x/f.go", new: "src/y/f.go", expected: "src/{x => y}/f.go"},
+		{old: "src/f.go", new: "lib/f.go", expected: "{src => lib}/f.go"},
+		{old: "a/b.txt", new: "a/c/b.txt", expected: "a/{ => c}/b.txt"},
+		{old: "a/c/b.txt", new: "a/b.txt", expected: "a/{c => }/b.txt"},
+		{old: "abc/f", new: "abd/f", expected: "{abc => abd}/f"},
+		{old: "ab/c", new: "a/c", expected: "{ab => a}/c"},
+		{old: "one/two.txt", new: "three.txt", expected: "one/two.txt => three.txt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			if got := renameName(tt.old, tt.new); got != tt.expected {
+				t.Errorf("renameName(%q, %q) = %q, want %q", tt.old, tt.new, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestWriteStatRenames(t *testing.T) {
+	// A rename without changes is listed with a count of 0, as git does.
+	moved := statOf("d/keep.txt", seqLines(1, 5), seqLines(1, 5), false)
+	moved.OldPath = "keep.txt"
+	edited := statOf("h.txt", seqLines(1, 20), strings.Replace(seqLines(1, 20), "3\n", "three\n", 1), false)
+	edited.OldPath = "f.txt"
+	unmoved := statOf("same.txt", "a\n", "a\n", false)
+	unmoved.OldPath = "same.txt"
+	stats := []FileStat{moved, edited, unmoved}
+
+	var stat, numstat strings.Builder
+	if err := WriteStat(&stat, stats, StatWidth); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteNumstat(&numstat, stats); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := " keep.txt => d/keep.txt | 0\n" +
+		" f.txt => h.txt         | 2 +-\n" +
+		" 2 files changed, 1 insertion(+), 1 deletion(-)\n"
+	if stat.String() != expected {
+		t.Errorf("WriteStat() =\n%s\nwant\n%s", stat.String(), expected)
+	}
+	expected = "0\t0\tkeep.txt => d/keep.txt\n1\t1\tf.txt => h.txt\n"
+	if numstat.String() != expected {
+		t.Errorf("WriteNumstat() =\n%s\nwant\n%s", numstat.String(), expected)
+	}
+}
+
+func TestWriteShortstat(t *testing.T) {
+	stats := gitStats()
+	tests := []struct {
+		name     string
+		stats    []FileStat
+		expected string
+	}{
+		{name: "nothing changed", stats: stats[5:6]},
+		{name: "one file", stats: stats[6:7], expected: " 1 file changed, 1 insertion(+), 1 deletion(-)\n"},
+		{name: "only deletions", stats: stats[1:2], expected: " 1 file changed, 5 deletions(-)\n"},
+		{name: "only insertions", stats: stats[0:1], expected: " 1 file changed, 3 insertions(+)\n"},
+		{name: "only binary", stats: stats[2:3], expected: " 1 file changed, 0 insertions(+), 0 deletions(-)\n"},
+		{name: "all", stats: stats, expected: " 6 files changed, 206 insertions(+), 27 deletions(-)\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			if err := WriteShortstat(&b, tt.stats); err != nil {
+				t.Fatal(err)
+			}
+			if b.String() != tt.expected {
+				t.Errorf("WriteShortstat() = %q, want %q", b.String(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestStatLimitsBreaches(t *testing.T) {
+	stat := FileStat{Path: "f", Added: 30, Removed: 10, Unchanged: 80, Hunks: 2}
+	none := StatLimits{MaxAdded: -1, MaxRemoved: -1, MaxChangedPercent: -1}
+
+	tests := []struct {
+		name     string
+		limits   StatLimits
+		breaches []string
+	}{
+		{name: "no limits", limits: none},
+		{name: "within every limit", limits: StatLimits{MaxAdded: 30, MaxRemoved: 10, MaxChangedPercent: 20}},
+		{name: "too many added", limits: StatLimits{MaxAdded: 29, MaxRemoved: -1, MaxChangedPercent: -1},
+			breaches: []string{"30 lines added, over the limit of 29"}},
+		{name: "zero removals allowed", limits: StatLimits{MaxAdded: -1, MaxRemoved: 0, MaxChangedPercent: -1},
+			breaches: []string{"10 lines removed, over the limit of 0"}},
+		{name: "changed share", limits: StatLimits{MaxAdded: -1, MaxRemoved: -1, MaxChangedPercent: 12.5},
+			breaches: []string{"20.0% of lines changed, over the limit of 12.5%"}},
+		{name: "every limit", limits: StatLimits{MaxAdded: 0, MaxRemoved: 0, MaxChangedPercent: 0},
+			breaches: []string{
+				"30 lines added, over the limit of 0",
+				"10 lines removed, over the limit of 0",
+				"20.0% of lines changed, over the limit of 0%",
+			}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.limits.Breaches(stat)
+			if strings.Join(got, "\n") != strings.Join(tt.breaches, "\n") {
+				t.Errorf("Breaches() = %q, want %q", got, tt.breaches)
+			}
+		})
+	}
+}
+
+func TestChangedPercent(t *testing.T) {
+	tests := []struct {
+		name     string
+		stat     FileStat
+		expected float64
+	}{
+		{name: "empty", stat: FileStat{}, expected: 0},
+		{name: "new file", stat: FileStat{Added: 7}, expected: 100},
+		{name: "deleted file", stat: FileStat{Removed: 3}, expected: 100},
+		{name: "one line of a hundred", stat: FileStat{Added: 1, Removed: 1, Unchanged: 99}, expected: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.stat.ChangedPercent(); got != tt.expected {
+				t.Errorf("ChangedPercent() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,266 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/cj3636/gdiff/internal/config"
+)
+
+// SVG cell geometry in pixels. Each run of text is stretched to its cell
+// count with textLength, so columns line up whichever monospace font the
+// viewer substitutes.
+const (
+	svgFontSize   = 14
+	svgCellWidth  = 8.4
+	svgLineHeight = 18
+	svgPadding    = 12
+)
+
+// svgStyle is the SGR state of one terminal cell.
+type svgStyle struct {
+	fg, bg  string
+	bold    bool
+	faint   bool
+	italic  bool
+	under   bool
+	reverse bool
+}
+
+type svgCell struct {
+	text  string
+	width int
+	style svgStyle
+}
+
+// renderSVG draws the rows of opts.View, as the viewer printed them with ANSI
+// styling, as an SVG image of monospace text.
+func renderSVG(opts Options) (string, error) {
+	if len(opts.View) == 0 {
+		return "", errors.New("svg export needs the rendered view")
+	}
+
+	theme := config.DefaultTheme()
+	if opts.Theme != nil {
+		theme = *opts.Theme
+	}
+	defaultFg, background := string(theme.UnchangedFg), string(theme.Background)
+
+	rows := make([][]svgCell, len(opts.View))
+	columns := 0
+	for i, row := range opts.View {
+		rows[i] = parseANSICells(row)
+		width := 0
+		for _, cell := range rows[i] {
+			width += cell.width
+		}
+		columns = max(columns, width)
+	}
+
+	width := float64(columns)*svgCellWidth + 2*svgPadding
+	height := len(rows)*svgLineHeight + 2*svgPadding
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%s\" height=\"%d\" viewBox=\"0 0 %s %d\">\n",
+		svgNumber(width), height, svgNumber(width), height)
+	if opts.Title != "" {
+		fmt.Fprintf(&b, "<title>%s</title>\n", html.EscapeString(opts.Title))
+	}
+	fmt.Fprintf(&b, "<style>text{font-family:Menlo,Consolas,'DejaVu Sans Mono',monospace;font-size:%dpx;white-space:pre;}.b{font-weight:bold;}.f{opacity:0.6;}.i{font-style:italic;}.u{text-decoration:underline;}</style>\n", svgFontSize)
+	fmt.Fprintf(&b, "<rect width=\"100%%\" height=\"100%%\" fill=\"%s\"/>\n", background)
+
+	for y, row := range rows {
+		top := svgPadding + y*svgLineHeight
+		col := 0
+		for _, run := range svgRuns(row) {
+			fg, bg := run.style.fg, run.style.bg
+			if fg == "" {
+				fg = defaultFg
+			}
+			if run.style.reverse {
+				fg, bg = bg, fg
+				if fg == "" {
+					fg = background
+				}
+			}
+
+			x := svgPadding + float64(col)*svgCellWidth
+			runWidth := float64(run.width) * svgCellWidth
+			if bg != "" {
+				fmt.Fprintf(&b, "<rect x=\"%s\" y=\"%d\" width=\"%s\" height=\"%d\" fill=\"%s\"/>\n",
+					svgNumber(x), top, svgNumber(runWidth), svgLineHeight, bg)
+			}
+			if text := strings.TrimRight(run.text, " "); text != "" {
+				textWidth := float64(run.width-(len(run.text)-len(text))) * svgCellWidth
+				fmt.Fprintf(&b, "<text x=\"%s\" y=\"%d\" fill=\"%s\"%s textLength=\"%s\" lengthAdjust=\"spacingAndGlyphs\" xml:space=\"preserve\">%s</text>\n",
+					svgNumber(x), top+svgLineHeight-5, fg, svgClasses(run.style), svgNumber(textWidth), html.EscapeString(text))
+			}
+			col += run.width
+		}
+	}
+	b.WriteString("</svg>\n")
+	return b.String(), nil
+}
+
+// svgRuns merges neighbouring cells of the same style.
+func svgRuns(cells []svgCell) []svgCell {
+	var runs []svgCell
+	for _, cell := range cells {
+		if n := len(runs); n > 0 && runs[n-1].style == cell.style {
+			runs[n-1].text += cell.text
+			runs[n-1].width += cell.width
+			continue
+		}
+		runs = append(runs, cell)
+	}
+	return runs
+}
+
+func svgClasses(style svgStyle) string {
+	var classes []string
+	for _, c := range []struct {
+		on   bool
+		name string
+	}{{style.bold, "b"}, {style.faint, "f"}, {style.italic, "i"}, {style.under, "u"}} {
+		if c.on {
+			classes = append(classes, c.name)
+		}
+	}
+	if len(classes) == 0 {
+		return ""
+	}
+	return " class=\"" + strings.Join(classes, " ") + "\""
+}
+
+// svgNumber formats a coordinate to two decimal places at most.
+func svgNumber(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
+
+// parseANSICells splits a styled line into cells, applying SGR sequences and
+// dropping any other escape sequence.
+func parseANSICells(line string) []svgCell {
+	var cells []svgCell
+	var style svgStyle
+	for i := 0; i < len(line); {
+		if line[i] == '\x1b' && i+1 < len(line) && line[i+1] == '[' {
+			end := i + 2
+			for end < len(line) && (line[end] < 0x40 || line[end] > 0x7e) {
+				end++
+			}
+			if end < len(line) && line[end] == 'm' {
+				style = applySGR(style, line[i+2:end])
+			}
+			i = end + 1
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(line[i:])
+		i += size
+		if r == '\x1b' {
+			continue
+		}
+		cells = append(cells, svgCell{text: string(r), width: lipgloss.Width(string(r)), style: style})
+	}
+	return cells
+}
+
+func applySGR(style svgStyle, params string) svgStyle {
+	codes := strings.Split(params, ";")
+	for k := 0; k < len(codes); k++ {
+		code, _ := strconv.Atoi(codes[k])
+		switch {
+		case code == 0:
+			style = svgStyle{}
+		case code == 1:
+			style.bold = true
+		case code == 2:
+			style.faint = true
+		case code == 3:
+			style.italic = true
+		case code == 4:
+			style.under = true
+		case code == 7:
+			style.reverse = true
+		case code == 22:
+			style.bold, style.faint = false, false
+		case code == 23:
+			style.italic = false
+		case code == 24:
+			style.under = false
+		case code == 27:
+			style.reverse = false
+		case code >= 30 && code <= 37:
+			style.fg = xtermHex(code - 30)
+		case code >= 90 && code <= 97:
+			style.fg = xtermHex(code - 90 + 8)
+		case code == 39:
+			style.fg = ""
+		case code >= 40 && code <= 47:
+			style.bg = xtermHex(code - 40)
+		case code >= 100 && code <= 107:
+			style.bg = xtermHex(code - 100 + 8)
+		case code == 49:
+			style.bg = ""
+		case code == 38 || code == 48:
+			color, used := extendedColor(codes[k+1:])
+			k += used
+			if code == 38 {
+				style.fg = color
+			} else {
+				style.bg = color
+			}
+		}
+	}
+	return style
+}
+
+// extendedColor reads the 5;n or 2;r;g;b arguments of SGR 38 and 48 and
+// returns the colour with the number of parameters consumed.
+func extendedColor(args []string) (string, int) {
+	if len(args) >= 2 && args[0] == "5" {
+		n, _ := strconv.Atoi(args[1])
+		return xtermHex(n), 2
+	}
+	if len(args) >= 4 && args[0] == "2" {
+		var rgb [3]int
+		for c := range rgb {
+			rgb[c], _ = strconv.Atoi(args[1+c])
+		}
+		return fmt.Sprintf("#%02x%02x%02x", rgb[0], rgb[1], rgb[2]), 4
+	}
+	return "", len(args)
+}
+
+var xtermBasic = [16]string{
+	"#000000", "#cd0000", "#00cd00", "#cdcd00", "#0000ee", "#cd00cd", "#00cdcd", "#e5e5e5",
+	"#7f7f7f", "#ff0000", "#00ff00", "#ffff00", "#5c5cff", "#ff00ff", "#00ffff", "#ffffff",
+}
+
+// xtermHex returns the RGB value of an xterm 256-colour palette entry.
+func xtermHex(n int) string {
+	switch {
+	case n < 0 || n > 255:
+		return ""
+	case n < 16:
+		return xtermBasic[n]
+	case n < 232:
+		n -= 16
+		level := func(l int) int {
+			if l == 0 {
+				return 0
+			}
+			return 55 + l*40
+		}
+		return fmt.Sprintf("#%02x%02x%02x", level(n/36), level(n/6%6), level(n%6))
+	default:
+		v := 8 + (n-232)*10
+		return fmt.Sprintf("#%02x%02x%02x", v, v, v)
+	}
+}
@@ -0,0 +1,127 @@
+package export
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cj3636/gdiff/internal/config"
+	"github.com/cj3636/gdiff/internal/difftest"
+)
+
+func TestApplySGR(t *testing.T) {
+	bold := svgStyle{bold: true, fg: "#ff0000"}
+
+	tests := []struct {
+		name     string
+		from     svgStyle
+		params   string
+		expected svgStyle
+	}{
+		{name: "reset", from: bold, params: "0"},
+		{name: "empty resets", from: bold, params: ""},
+		{name: "attributes", params: "1;2;3;4;7", expected: svgStyle{bold: true, faint: true, italic: true, under: true, reverse: true}},
+		{name: "normal intensity clears bold and faint", from: svgStyle{bold: true, faint: true, italic: true}, params: "22",
+			expected: svgStyle{italic: true}},
+		{name: "attributes off", from: svgStyle{italic: true, under: true, reverse: true}, params: "23;24;27"},
+		{name: "basic colours", params: "31;42", expected: svgStyle{fg: "#cd0000", bg: "#00cd00"}},
+		{name: "bright colours", params: "94;103", expected: svgStyle{fg: "#5c5cff", bg: "#ffff00"}},
+		{name: "default colours", from: svgStyle{fg: "#cd0000", bg: "#00cd00", bold: true}, params: "39;49",
+			expected: svgStyle{bold: true}},
+		{name: "256 colours", params: "38;5;196;48;5;240", expected: svgStyle{fg: "#ff0000", bg: "#585858"}},
+		{name: "colour cube", params: "38;5;22", expected: svgStyle{fg: "#005f00"}},
+		{name: "true colour then more", params: "38;2;1;2;3;1", expected: svgStyle{fg: "#010203", bold: true}},
+		{name: "true colour background", params: "48;2;255;128;0", expected: svgStyle{bg: "#ff8000"}},
+		{name: "incomplete extended colour", from: bold, params: "38;5", expected: svgStyle{bold: true}},
+		{name: "unknown codes are ignored", from: bold, params: "53;73", expected: bold},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := applySGR(tt.from, tt.params); got != tt.expected {
+				t.Errorf("applySGR(%+v, %q) = %+v, want %+v", tt.from, tt.params, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseANSICells(t *testing.T) {
+	red := svgStyle{fg: "#cd0000"}
+
+	tests := []struct {
+		name     string
+		line     string
+		expected []svgCell
+	}{
+		{name: "plain", line: "ab", expected: []svgCell{{text: "a", width: 1}, {text: "b", width: 1}}},
+		{
+			name:     "styles apply until reset",
+			line:     "a\x1b[31mb\x1b[0mc",
+			expected: []svgCell{{text: "a", width: 1}, {text: "b", width: 1, style: red}, {text: "c", width: 1}},
+		},
+		{name: "wide runes take two cells", line: "世x", expected: []svgCell{{text: "世", width: 2}, {text: "x", width: 1}}},
+		{
+			name:     "other sequences are dropped",
+			line:     "\x1b[2K\x1b[31m\x1b[?25la\x1b",
+			expected: []svgCell{{text: "a", width: 1, style: red}},
+		},
+		{name: "unterminated sequence", line: "a\x1b[31", expected: []svgCell{{text: "a", width: 1}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseANSICells(tt.line)
+			if len(got) != len(tt.expected) {
+				t.Fatalf("parseANSICells(%q) = %+v, want %+v", tt.line, got, tt.expected)
+			}
+			for i := range got {
+				if got[i] != tt.expected[i] {
+					t.Errorf("cell %d = %+v, want %+v", i, got[i], tt.expected[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRenderSVG(t *testing.T) {
+	view := []string{
+		"\x1b[1;38;2;255;0;0mab\x1b[0m <x>  ",
+		"\x1b[7m世\x1b[27m\x1b[48;5;22mz \x1b[0m",
+	}
+	expected := `<svg xmlns="http://www.w3.org/2000/svg" width="91.2" height="60" viewBox="0 0 91.2 60">
+<title>a &amp; b</title>
+<style>text{font-family:Menlo,Consolas,'DejaVu Sans Mono',monospace;font-size:14px;white-space:pre;}.b{font-weight:bold;}.f{opacity:0.6;}.i{font-style:italic;}.u{text-decoration:underline;}</style>
+<rect width="100%" height="100%" fill="#0F111A"/>
+<text x="12" y="25" fill="#ff0000" class="b" textLength="16.8" lengthAdjust="spacingAndGlyphs" xml:space="preserve">ab</text>
+<text x="28.8" y="25" fill="#B0B0B0" textLength="33.6" lengthAdjust="spacingAndGlyphs" xml:space="preserve"> &lt;x&gt;</text>
+<rect x="12" y="30" width="16.8" height="18" fill="#B0B0B0"/>
+<text x="12" y="43" fill="#0F111A" textLength="16.8" lengthAdjust="spacingAndGlyphs" xml:space="preserve">世</text>
+<rect x="28.8" y="30" width="16.8" height="18" fill="#005f00"/>
+<text x="28.8" y="43" fill="#B0B0B0" textLength="8.4" lengthAdjust="spacingAndGlyphs" xml:space="preserve">z</text>
+</svg>
+`
+
+	result := difftest.Texts("a\n", "b\n")
+	got, err := Render(result, FormatSVG, Options{View: view, Title: "a & b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != expected {
+		t.Errorf("Render(svg) =\n%s\nwant\n%s", got, expected)
+	}
+
+	// The page and reversed text take the theme's background.
+	theme := config.DefaultTheme()
+	theme.Background = "#002B36"
+	got, err = Render(result, FormatSVG, Options{View: view, Theme: &theme})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, `<rect width="100%" height="100%" fill="#002B36"/>`) ||
+		!strings.Contains(got, `fill="#002B36" textLength="16.8" lengthAdjust="spacingAndGlyphs" xml:space="preserve">世</text>`) {
+		t.Errorf("Render(svg) with a theme background =\n%s", got)
+	}
+
+	if _, err := Render(result, FormatSVG, Options{}); err == nil {
+		t.Error("Render(svg) without a view succeeded")
+	}
+}
@@ -0,0 +1,225 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/cj3636/gdiff/internal/config"
+	"github.com/cj3636/gdiff/internal/diff"
+)
+
+// templateExt marks export templates in a template directory. The rest of the
+// file name is the template name, whose own extension picks the engine and the
+// output file type: review.html.tmpl is an html/template called review.html,
+// notes.md.tmpl a text/template called notes.md.
+const templateExt = ".tmpl"
+
+// TemplateFile is an export template found in a template directory.
+type TemplateFile struct {
+	Name string
+	Path string
+}
+
+// HTML reports whether the template produces HTML and so runs through
+// html/template, which escapes values for the context they appear in.
+func (t TemplateFile) HTML() bool {
+	switch strings.ToLower(filepath.Ext(t.Name)) {
+	case ".html", ".htm":
+		return true
+	}
+	return false
+}
+
+// Extension is the file extension of the output, without the dot.
+func (t TemplateFile) Extension() string {
+	if ext := filepath.Ext(t.Name); ext != "" {
+		return ext[1:]
+	}
+	return "txt"
+}
+
+// ListTemplates returns the templates in dir sorted by name. A missing
+// directory has no templates.
+func ListTemplates(dir string) ([]TemplateFile, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var templates []TemplateFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, templateExt) {
+			continue
+		}
+		templates = append(templates, TemplateFile{Name: strings.TrimSuffix(name, templateExt), Path: filepath.Join(dir, name)})
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name < templates[j].Name })
+	return templates, nil
+}
+
+// FindTemplate resolves name to a template in dir, matching either the full
+// template name or the name without its extension. A name that is a path to
+// an existing file is used as is.
+func FindTemplate(dir, name string) (TemplateFile, error) {
+	if info, err := os.Stat(name); err == nil && !info.IsDir() {
+		return TemplateFile{Name: strings.TrimSuffix(filepath.Base(name), templateExt), Path: name}, nil
+	}
+
+	templates, err := ListTemplates(dir)
+	if err != nil {
+		return TemplateFile{}, err
+	}
+	for _, t := range templates {
+		if t.Name == name {
+			return t, nil
+		}
+	}
+	for _, t := range templates {
+		if strings.TrimSuffix(t.Name, filepath.Ext(t.Name)) == name {
+			return t, nil
+		}
+	}
+	if dir == "" {
+		return TemplateFile{}, fmt.Errorf("template %q not found", name)
+	}
+	return TemplateFile{}, fmt.Errorf("template %q not found in %s", name, dir)
+}
+
+// TemplateData is the value templates execute against.
+type TemplateData struct {
+	Title   string
+	OldPath string
+	NewPath string
+	Result  *diff.DiffResult
+	Lines   []diff.DiffLine
+	Hunks   []TemplateHunk
+	Stats   TemplateStats
+	Theme   config.Theme
+}
+
+// TemplateHunk is a hunk together with its header and lines.
+type TemplateHunk struct {
+	diff.Hunk
+	Header string
+	Lines  []diff.DiffLine
+}
+
+// TemplateStats counts the lines of each type.
+type TemplateStats struct {
+	Added     int
+	Removed   int
+	Unchanged int
+}
+
+// TemplateSpan is a stretch of a line inside or outside a changed token.
+type TemplateSpan struct {
+	Text    string
+	Changed bool
+}
+
+// templateFuncs are available to every template:
+//
+//	symbol   "+", "-" or " " for a line
+//	kind     "added", "removed" or "unchanged" for a line
+//	lineno   a line number, or "" for the side a line is missing from
+//	escape   HTML-escapes a string, for text templates that write HTML
+//	spans    splits a line into TemplateSpans at its changed tokens
+//	mark     a line as HTML with changed tokens wrapped in <mark>
+var templateFuncs = map[string]any{
+	"symbol": func(line diff.DiffLine) string { return lineSymbol(line.Type) },
+	"kind": func(line diff.DiffLine) string {
+		switch line.Type {
+		case diff.Added:
+			return "added"
+		case diff.Removed:
+			return "removed"
+		default:
+			return "unchanged"
+		}
+	},
+	"lineno": func(no int) string {
+		if no <= 0 {
+			return ""
+		}
+		return strconv.Itoa(no)
+	},
+	"escape": html.EscapeString,
+	"spans": func(line diff.DiffLine) []TemplateSpan {
+		var spans []TemplateSpan
+		for _, seg := range highlightSegments(line) {
+			spans = append(spans, TemplateSpan{Text: seg.text, Changed: seg.marked})
+		}
+		return spans
+	},
+	"mark": func(line diff.DiffLine) htmltemplate.HTML {
+		return htmltemplate.HTML(htmlContent(line))
+	},
+}
+
+func renderTemplate(result *diff.DiffResult, opts Options) (string, error) {
+	if opts.Template == "" {
+		return "", errors.New("no template selected")
+	}
+	source, err := os.ReadFile(opts.Template)
+	if err != nil {
+		return "", err
+	}
+	file := TemplateFile{Name: strings.TrimSuffix(filepath.Base(opts.Template), templateExt), Path: opts.Template}
+
+	theme := config.DefaultTheme()
+	if opts.Theme != nil {
+		theme = *opts.Theme
+	}
+	added, removed, unchanged := result.GetStats()
+	data := TemplateData{
+		Title:   opts.Title,
+		OldPath: opts.OldPath,
+		NewPath: opts.NewPath,
+		Result:  result,
+		Lines:   result.Lines,
+		Stats:   TemplateStats{Added: added, Removed: removed, Unchanged: unchanged},
+		Theme:   theme,
+	}
+	if data.OldPath == "" {
+		data.OldPath = result.File1Name
+	}
+	if data.NewPath == "" {
+		data.NewPath = result.File2Name
+	}
+	for _, h := range result.Hunks(opts.Context) {
+		data.Hunks = append(data.Hunks, TemplateHunk{Hunk: h, Header: result.HunkHeader(h), Lines: result.Lines[h.Start:h.End]})
+	}
+
+	var b strings.Builder
+	if file.HTML() {
+		tmpl, err := htmltemplate.New(file.Name).Funcs(templateFuncs).Parse(string(source))
+		if err != nil {
+			return "", err
+		}
+		err = tmpl.Execute(&b, data)
+		return b.String(), err
+	}
+
+	tmpl, err := texttemplate.New(file.Name).Funcs(templateFuncs).Parse(string(source))
+	if err != nil {
+		return "", err
+	}
+	err = tmpl.Execute(&b, data)
+	return b.String(), err
+}
@@ -0,0 +1,166 @@
+package export
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cj3636/gdiff/internal/difftest"
+)
+
+// writeTemplates creates the named files in a new directory, and a
+// directory for each name ending in "/".
+func writeTemplates(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		var err error
+		if strings.HasSuffix(name, "/") {
+			err = os.Mkdir(path, 0o755)
+		} else {
+			err = os.WriteFile(path, []byte(content), 0o644)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestListTemplates(t *testing.T) {
+	dir := writeTemplates(t, map[string]string{
+		"review.html.tmpl": "", "notes.md.tmpl": "", "plain.tmpl": "", "readme.txt": "", "nested.tmpl/": "",
+	})
+
+	tests := []struct {
+		name  string
+		dir   string
+		names []string
+	}{
+		{name: "template files sorted by name", dir: dir, names: []string{"notes.md", "plain", "review.html"}},
+		{name: "missing directory", dir: filepath.Join(dir, "missing")},
+		{name: "no directory", dir: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			templates, err := ListTemplates(tt.dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, tmpl := range templates {
+				names = append(names, tmpl.Name)
+				if tmpl.Path != filepath.Join(tt.dir, tmpl.Name+templateExt) {
+					t.Errorf("%s has path %s", tmpl.Name, tmpl.Path)
+				}
+			}
+			if strings.Join(names, " ") != strings.Join(tt.names, " ") {
+				t.Errorf("ListTemplates() = %q, want %q", names, tt.names)
+			}
+		})
+	}
+}
+
+func TestFindTemplate(t *testing.T) {
+	dir := writeTemplates(t, map[string]string{
+		"review.html.tmpl": "", "review.md.tmpl": "", "notes.tmpl": "", "notes.txt.tmpl": "",
+	})
+	outside := writeTemplates(t, map[string]string{"review.html.tmpl": ""})
+	outsidePath := filepath.Join(outside, "review.html.tmpl")
+
+	tests := []struct {
+		name     string
+		dir      string
+		lookup   string
+		expected TemplateFile
+		err      string
+	}{
+		{name: "full name", dir: dir, lookup: "review.md",
+			expected: TemplateFile{Name: "review.md", Path: filepath.Join(dir, "review.md.tmpl")}},
+		{name: "name without its extension takes the first by name", dir: dir, lookup: "review",
+			expected: TemplateFile{Name: "review.html", Path: filepath.Join(dir, "review.html.tmpl")}},
+		{name: "full name before one without its extension", dir: dir, lookup: "notes",
+			expected: TemplateFile{Name: "notes", Path: filepath.Join(dir, "notes.tmpl")}},
+		{name: "a file path is used as is", dir: dir, lookup: outsidePath,
+			expected: TemplateFile{Name: "review.html", Path: outsidePath}},
+		{name: "a directory path is not a template", dir: dir, lookup: outside, err: "not found in " + dir},
+		{name: "unknown name", dir: dir, lookup: "summary", err: `template "summary" not found in ` + dir},
+		{name: "no directory", dir: "", lookup: "review", err: `template "review" not found`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FindTemplate(tt.dir, tt.lookup)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("FindTemplate() error = %v, want it to contain %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.expected {
+				t.Errorf("FindTemplate() = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRenderTemplate(t *testing.T) {
+	result := difftest.Texts("a\nx := 1\n", "a\nx := <b>\n")
+
+	tests := []struct {
+		name     string
+		file     string
+		source   string
+		expected string
+	}{
+		{
+			name:     "text template",
+			file:     "notes.md.tmpl",
+			source:   "{{.OldPath}} -> {{.NewPath}} +{{.Stats.Added}} -{{.Stats.Removed}}\n{{range .Lines}}{{symbol .}}{{lineno .LineNo1}}:{{lineno .LineNo2}} {{.Content}}\n{{end}}",
+			expected: "old.txt -> new.txt +1 -1\n 1:1 a\n-2: x := 1\n+:2 x := <b>\n",
+		},
+		{
+			name:     "hunks",
+			file:     "hunks.txt.tmpl",
+			source:   "{{range .Hunks}}{{.Header}} {{len .Lines}} lines from {{.OldStart}}\n{{end}}",
+			expected: "@@ -1,2 +1,2 @@ 3 lines from 1\n",
+		},
+		{
+			name:     "text templates escape on request",
+			file:     "page.txt.tmpl",
+			source:   "{{range .Lines}}{{kind .}} {{escape .Content}}\n{{end}}",
+			expected: "unchanged a\nremoved x := 1\nadded x := &lt;b&gt;\n",
+		},
+		{
+			name:     "spans mark the changed tokens",
+			file:     "spans.txt.tmpl",
+			source:   "{{range .Lines}}{{range spans .}}{{if .Changed}}[{{.Text}}]{{else}}{{.Text}}{{end}}{{end}}\n{{end}}",
+			expected: "a\nx := [1]\nx := [<b>]\n",
+		},
+		{
+			name:     "html templates escape values",
+			file:     "review.html.tmpl",
+			source:   "{{range .Lines}}<p class=\"{{kind .}}\">{{.Content}}|{{mark .}}</p>\n{{end}}",
+			expected: "<p class=\"unchanged\">a|a</p>\n<p class=\"removed\">x := 1|x := <mark>1</mark></p>\n<p class=\"added\">x := &lt;b&gt;|x := <mark>&lt;b&gt;</mark></p>\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeTemplates(t, map[string]string{tt.file: tt.source})
+			got, err := Render(result, FormatTemplate, Options{Context: 3, Template: filepath.Join(dir, tt.file)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.expected {
+				t.Errorf("Render(template) =\n%s\nwant\n%s", got, tt.expected)
+			}
+		})
+	}
+}
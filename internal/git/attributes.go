@@ -0,0 +1,178 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// binarySniffLen is how much of a file git inspects for NUL bytes when
+// deciding whether it is binary.
+const binarySniffLen = 8000
+
+// Driver is the diff behaviour .gitattributes selects for a path.
+type Driver struct {
+	Name      string // diff=<name>; empty for the built-in driver
+	Binary    bool   // -diff, binary, or diff.<name>.binary
+	Text      bool   // diff set explicitly, so content is never sniffed
+	Textconv  string // diff.<name>.textconv command
+	XFuncName string // diff.<name>.xfuncname pattern
+}
+
+// Drivers reads the diff attribute of each path with git check-attr and
+// resolves the named drivers against the repository configuration. An empty
+// path gets the built-in driver.
+func (r *Repo) Drivers(ctx context.Context, paths ...string) ([]Driver, error) {
+	args := []string{"check-attr", "-z", "diff", "--"}
+	for _, path := range paths {
+		if path != "" {
+			args = append(args, path)
+		}
+	}
+
+	values := map[string]string{}
+	if len(args) > 4 {
+		out, err := r.Run(ctx, args...)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read attributes: %w", err)
+		}
+		// Records are <path> NUL <attribute> NUL <value> NUL.
+		fields := strings.Split(string(out), "\x00")
+		for i := 0; i+2 < len(fields); i += 3 {
+			values[fields[i]] = fields[i+2]
+		}
+	}
+
+	config, err := r.diffConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	drivers := make([]Driver, len(paths))
+	for i, path := range paths {
+		switch value := values[path]; value {
+		case "", "unspecified":
+		case "unset":
+			drivers[i].Binary = true
+		case "set":
+			drivers[i].Text = true
+		default:
+			binary, err := config["diff."+value+".binary"].bool()
+			if err != nil {
+				return nil, fmt.Errorf("diff.%s.binary: %w", value, err)
+			}
+			drivers[i] = Driver{
+				Name:      value,
+				Binary:    binary,
+				Textconv:  config["diff."+value+".textconv"].value,
+				XFuncName: config["diff."+value+".xfuncname"].value,
+			}
+		}
+	}
+	return drivers, nil
+}
+
+// configValue is one configuration setting. A bare key, written without
+// "=", has no value and counts as true.
+type configValue struct {
+	value string
+	bare  bool
+}
+
+// bool reads the setting as git config --type=bool does: true, yes and on
+// in any case, a bare key, or a nonzero integer are true; false, no, off,
+// an empty value, zero or a missing setting are false.
+func (c configValue) bool() (bool, error) {
+	if c.bare {
+		return true, nil
+	}
+	switch strings.ToLower(c.value) {
+	case "true", "yes", "on":
+		return true, nil
+	case "false", "no", "off", "":
+		return false, nil
+	}
+	n, err := strconv.ParseInt(c.value, 0, 64)
+	if err != nil {
+		return false, fmt.Errorf("bad boolean config value %q", c.value)
+	}
+	return n != 0, nil
+}
+
+// diffConfig loads every diff.* setting once per handle.
+func (r *Repo) diffConfig(ctx context.Context) (map[string]configValue, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.config != nil {
+		return r.config, nil
+	}
+
+	out, err := r.Run(ctx, "config", "-z", "--get-regexp", `^diff\.`)
+	var exit *exec.ExitError
+	if err != nil && !(errors.As(err, &exit) && exit.ExitCode() == 1) {
+		return nil, fmt.Errorf("unable to read diff configuration: %w", err)
+	}
+
+	// Entries are <key> LF <value> NUL, or just <key> NUL for a bare key;
+	// keys come back lower-cased except for the driver name in the middle.
+	config := map[string]configValue{}
+	for _, entry := range strings.Split(string(out), "\x00") {
+		key, value, found := strings.Cut(entry, "\n")
+		if key != "" {
+			config[key] = configValue{value: value, bare: !found}
+		}
+	}
+	r.config = config
+	return config, nil
+}
+
+// Textconv runs the driver's textconv command on data the way git does: the
+// content is written to a temporary file whose name is appended to the
+// command, which is run by the shell from the repository root.
+func (r *Repo) Textconv(ctx context.Context, d Driver, data []byte) ([]byte, error) {
+	if d.Textconv == "" || data == nil {
+		return data, nil
+	}
+
+	tmp, err := os.CreateTemp("", "gdiff-textconv-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", d.Textconv+` "$@"`, d.Textconv, tmp.Name())
+	cmd.Dir = r.Root
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("textconv %q: %w", d.Textconv, err)
+	}
+	return out, nil
+}
+
+// IsBinary applies git's heuristic: a NUL byte near the start of the file.
+func IsBinary(data []byte) bool {
+	return bytes.IndexByte(data[:min(len(data), binarySniffLen)], 0) != -1
+}
+
+// BlobID returns the object id git would give data, as git hash-object does.
+func BlobID(data []byte) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", len(data))
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,38 @@
+package git
+
+import "testing"
+
+func TestConfigValueBool(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    configValue
+		expected bool
+		err      bool
+	}{
+		{name: "missing", value: configValue{}},
+		{name: "bare key", value: configValue{bare: true}, expected: true},
+		{name: "true", value: configValue{value: "true"}, expected: true},
+		{name: "any case", value: configValue{value: "YES"}, expected: true},
+		{name: "on", value: configValue{value: "On"}, expected: true},
+		{name: "one", value: configValue{value: "1"}, expected: true},
+		{name: "other integers", value: configValue{value: "-2"}, expected: true},
+		{name: "false", value: configValue{value: "False"}},
+		{name: "no", value: configValue{value: "no"}},
+		{name: "off", value: configValue{value: "off"}},
+		{name: "zero", value: configValue{value: "0"}},
+		{name: "empty value", value: configValue{value: ""}},
+		{name: "not a boolean", value: configValue{value: "maybe"}, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.value.bool()
+			if (err != nil) != tt.err {
+				t.Fatalf("bool() error = %v, want error %v", err, tt.err)
+			}
+			if got != tt.expected {
+				t.Errorf("bool() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
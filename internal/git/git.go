@@ -0,0 +1,492 @@
+package git
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Worktree is the pseudo-revision naming the files on disk.
+const Worktree = "WORKTREE"
+
+// LinkedWorktreePrefix marks a pseudo-revision that reads files from another
+// linked worktree of the same repository, as in "worktree:../feature".
+const LinkedWorktreePrefix = "worktree:"
+
+// maxCachedBlobs bounds the blob cache; it is cleared once it fills up.
+const maxCachedBlobs = 256
+
+// ErrNotFound is returned when a revision or path does not exist.
+var ErrNotFound = errors.New("object not found")
+
+// Repo is a handle on a repository. Object reads and revision lookups go
+// through one long-lived git cat-file --batch process, and blobs and full
+// object ids are cached. Symbolic revisions such as HEAD, branches and
+// stash@{0} are looked up again on every use, so the viewer follows commits,
+// checkouts and stashes made while it runs.
+//
+// Everything cat-file cannot answer goes through Run instead: listings and
+// history (branch, for-each-ref, log, log -L, blame, merge-base, diff
+// --name-status for renames and submodules, status), ls-tree and ls-files
+// for submodule commits, rev-parse for upstreams and the current branch, and
+// config and check-attr for diff drivers.
+type Repo struct {
+	Root string
+
+	mu     sync.Mutex
+	batch  *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	revs   map[string]string // full object id to commit id
+	blobs  map[string][]byte
+	config map[string]configValue
+}
+
+// File names a path at a revision. An empty Path stands for a file missing on
+// that side and reads as empty.
+type File struct {
+	Rev  string
+	Path string
+}
+
+var (
+	reposMu sync.Mutex
+	repos   = map[string]*Repo{}
+)
+
+// Open returns the shared handle for the repository at root. The batch
+// process is started on first use.
+func Open(root string) *Repo {
+	reposMu.Lock()
+	defer reposMu.Unlock()
+
+	if repo, ok := repos[root]; ok {
+		return repo
+	}
+	repo := &Repo{Root: root, revs: map[string]string{}, blobs: map[string][]byte{}}
+	repos[root] = repo
+	return repo
+}
+
+// CloseAll stops every batch process started by Open.
+func CloseAll() {
+	reposMu.Lock()
+	defer reposMu.Unlock()
+
+	for _, repo := range repos {
+		repo.Close()
+	}
+}
+
+// TopLevel returns the root of the work tree containing dir.
+func TopLevel(dir string) (string, error) {
+	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// IsWorktree reports whether rev refers to the working tree.
+func IsWorktree(rev string) bool {
+	return rev == "" || rev == Worktree
+}
+
+// LinkedWorktree returns the directory named by a worktree:<dir> revision.
+func LinkedWorktree(rev string) (string, bool) {
+	dir, ok := strings.CutPrefix(rev, LinkedWorktreePrefix)
+	return dir, ok && dir != ""
+}
+
+// OnDisk reports whether rev is read from the filesystem rather than the
+// object database.
+func OnDisk(rev string) bool {
+	_, linked := LinkedWorktree(rev)
+	return IsWorktree(rev) || linked
+}
+
+// IsStash reports whether rev names a stash entry such as stash@{1}.
+func IsStash(rev string) bool {
+	rev = strings.TrimPrefix(rev, "refs/")
+	return rev == "stash" || strings.HasPrefix(rev, "stash@{")
+}
+
+// Close stops the batch process. The handle restarts it on the next read.
+func (r *Repo) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stopLocked()
+}
+
+// Run executes a git subcommand in the repository and returns its stdout.
+// The process is killed when ctx is cancelled.
+func (r *Repo) Run(ctx context.Context, args ...string) ([]byte, error) {
+	return exec.CommandContext(ctx, "git", append([]string{"-C", r.Root}, args...)...).Output()
+}
+
+// Lines runs a git subcommand and splits its trimmed output into lines.
+func (r *Repo) Lines(ctx context.Context, args ...string) ([]string, error) {
+	out, err := r.Run(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	text := strings.TrimSpace(string(out))
+	if text == "" {
+		return []string{}, nil
+	}
+	return strings.Split(text, "\n"), nil
+}
+
+// Resolve returns the commit id rev points at. Only full object ids are
+// cached, as what a name points at can move.
+func (r *Repo) Resolve(ctx context.Context, rev string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.resolveLocked(ctx, rev)
+}
+
+// Exists reports whether path exists at rev. A rev that does not resolve is
+// an error rather than a missing path.
+func (r *Repo) Exists(ctx context.Context, rev, path string) (bool, error) {
+	if file, ok := r.diskPath(rev, path); ok {
+		_, err := os.Stat(file)
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, found, err := r.lookupLocked(ctx, rev, path)
+	if err != nil {
+		return false, err
+	}
+	if !found {
+		_, found = r.gitlinkLocked(ctx, rev, path)
+	}
+	return found, nil
+}
+
+// Gitlink returns the commit recorded for the submodule at path on rev and
+// whether path is a submodule there. On disk the submodule's checked-out
+// HEAD is used, falling back to the index when it is not initialised.
+func (r *Repo) Gitlink(ctx context.Context, rev, path string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.gitlinkLocked(ctx, rev, path)
+}
+
+func (r *Repo) gitlinkLocked(ctx context.Context, rev, path string) (string, bool) {
+	args := []string{"ls-tree", rev, "--", path}
+	if dir, ok := r.diskPath(rev, path); ok {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			if head, err := exec.CommandContext(ctx, "git", "-C", dir, "rev-parse", "HEAD").Output(); err == nil {
+				return strings.TrimSpace(string(head)), true
+			}
+		}
+		args = []string{"ls-files", "-s", "--", path}
+	}
+
+	// ls-tree prints "160000 commit <oid>\t<path>", ls-files -s
+	// "160000 <oid> 0\t<path>"; 160000 is the gitlink mode.
+	out, err := r.Run(ctx, args...)
+	if err != nil {
+		return "", false
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) < 3 || fields[0] != "160000" {
+		return "", false
+	}
+	if fields[1] == "commit" {
+		return fields[2], true
+	}
+	return fields[1], true
+}
+
+// ReadFiles reads every file in one round trip to the batch process. Files
+// with an empty path yield nil content; a path missing from its revision is
+// an ErrNotFound error.
+func (r *Repo) ReadFiles(ctx context.Context, files []File) ([][]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	contents := make([][]byte, len(files))
+	var pending []string
+	var pendingIndex []int
+	for i, f := range files {
+		switch {
+		case f.Path == "":
+			continue
+		case OnDisk(f.Rev):
+			file, _ := r.diskPath(f.Rev, f.Path)
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+			contents[i] = data
+		case IsStash(f.Rev):
+			key, found, err := r.lookupLocked(ctx, f.Rev, f.Path)
+			if err != nil {
+				return nil, err
+			}
+			if !found {
+				return nil, fmt.Errorf("%s: %w", key, ErrNotFound)
+			}
+			contents[i] = r.blobs[key]
+		default:
+			oid, err := r.resolveLocked(ctx, f.Rev)
+			if err != nil {
+				return nil, err
+			}
+			key := oid + ":" + f.Path
+			if data, ok := r.blobs[key]; ok {
+				contents[i] = data
+				continue
+			}
+			pending = append(pending, key)
+			pendingIndex = append(pendingIndex, i)
+		}
+	}
+
+	if len(pending) == 0 {
+		return contents, nil
+	}
+
+	objects, err := r.catLocked(ctx, pending)
+	if err != nil {
+		return nil, err
+	}
+	for j, obj := range objects {
+		if obj.missing {
+			return nil, fmt.Errorf("%s: %w", pending[j], ErrNotFound)
+		}
+		r.storeLocked(pending[j], obj.data)
+		contents[pendingIndex[j]] = obj.data
+	}
+	return contents, nil
+}
+
+// diskPath returns where path lives for revisions read from the filesystem.
+func (r *Repo) diskPath(rev, path string) (string, bool) {
+	if IsWorktree(rev) {
+		return filepath.Join(r.Root, path), true
+	}
+	if dir, ok := LinkedWorktree(rev); ok {
+		return filepath.Join(dir, path), true
+	}
+	return "", false
+}
+
+// lookupLocked finds path at rev, loading it into the blob cache. Stash
+// entries keep untracked files in their third parent, which is tried when
+// the stash commit itself lacks the path.
+func (r *Repo) lookupLocked(ctx context.Context, rev, path string) (string, bool, error) {
+	oid, err := r.resolveLocked(ctx, rev)
+	if err != nil {
+		return "", false, err
+	}
+
+	keys := []string{oid + ":" + path}
+	if IsStash(rev) {
+		if untracked, err := r.resolveLocked(ctx, rev+"^3"); err == nil {
+			keys = append(keys, untracked+":"+path)
+		}
+	}
+
+	for _, key := range keys {
+		if _, ok := r.blobs[key]; ok {
+			return key, true, nil
+		}
+		objects, err := r.catLocked(ctx, []string{key})
+		if err != nil {
+			return "", false, err
+		}
+		if !objects[0].missing {
+			r.storeLocked(key, objects[0].data)
+			return key, true, nil
+		}
+	}
+	return keys[0], false, nil
+}
+
+func (r *Repo) storeLocked(key string, data []byte) {
+	if len(r.blobs) >= maxCachedBlobs {
+		r.blobs = map[string][]byte{}
+	}
+	r.blobs[key] = data
+}
+
+// ReadLines reads path at rev and splits it into lines.
+func (r *Repo) ReadLines(ctx context.Context, rev, path string) ([]string, error) {
+	contents, err := r.ReadFiles(ctx, []File{{Rev: rev, Path: path}})
+	if err != nil {
+		return nil, err
+	}
+	return SplitLines(contents[0]), nil
+}
+
+// SplitLines splits file content into lines, dropping the final newline.
+func SplitLines(data []byte) []string {
+	text := strings.TrimSuffix(string(data), "\n")
+	if text == "" {
+		return []string{}
+	}
+	return strings.Split(text, "\n")
+}
+
+func (r *Repo) resolveLocked(ctx context.Context, rev string) (string, error) {
+	if oid, ok := r.revs[rev]; ok {
+		return oid, nil
+	}
+
+	objects, err := r.catLocked(ctx, []string{rev + "^{commit}"})
+	if errors.Is(err, io.EOF) {
+		// cat-file exits instead of answering missing for some revisions,
+		// such as a reflog entry past the end like a dropped stash@{1}. It
+		// is restarted on the next read.
+		return "", fmt.Errorf("unknown revision %q", rev)
+	}
+	if err != nil {
+		return "", err
+	}
+	if objects[0].missing {
+		return "", fmt.Errorf("unknown revision %q", rev)
+	}
+
+	if IsObjectID(rev) {
+		r.revs[rev] = objects[0].oid
+	}
+	return objects[0].oid, nil
+}
+
+// IsObjectID reports whether rev is a full SHA-1 or SHA-256 object id.
+func IsObjectID(rev string) bool {
+	if len(rev) != 40 && len(rev) != 64 {
+		return false
+	}
+	return strings.Trim(rev, "0123456789abcdef") == ""
+}
+
+type object struct {
+	oid     string
+	data    []byte
+	missing bool
+}
+
+// catLocked sends every request up front and reads the replies as they
+// arrive, so a whole batch costs a single round trip. Requests are written
+// from a goroutine so a large batch cannot deadlock on full pipes. A
+// cancelled ctx aborts before the round trip starts.
+func (r *Repo) catLocked(ctx context.Context, names []string) ([]object, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := r.startLocked(); err != nil {
+		return nil, err
+	}
+
+	var request strings.Builder
+	for _, name := range names {
+		if strings.ContainsAny(name, "\n") {
+			return nil, fmt.Errorf("invalid object name %q", name)
+		}
+		request.WriteString(name)
+		request.WriteByte('\n')
+	}
+	written := make(chan error, 1)
+	go func(w io.Writer) {
+		_, err := io.WriteString(w, request.String())
+		written <- err
+	}(r.stdin)
+
+	objects := make([]object, len(names))
+	for i := range names {
+		obj, err := r.readObjectLocked()
+		if err != nil {
+			r.stopLocked()
+			<-written
+			return nil, err
+		}
+		objects[i] = obj
+	}
+	if err := <-written; err != nil {
+		r.stopLocked()
+		return nil, err
+	}
+	return objects, nil
+}
+
+func (r *Repo) readObjectLocked() (object, error) {
+	header, err := r.stdout.ReadString('\n')
+	if err != nil {
+		return object{}, err
+	}
+
+	if strings.HasSuffix(header, " missing\n") || strings.HasSuffix(header, " ambiguous\n") {
+		return object{missing: true}, nil
+	}
+	fields := strings.Fields(header)
+	if len(fields) != 3 {
+		return object{}, fmt.Errorf("unexpected cat-file header %q", strings.TrimSpace(header))
+	}
+
+	size, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return object{}, fmt.Errorf("unexpected cat-file header %q", strings.TrimSpace(header))
+	}
+
+	// The object is followed by a single newline.
+	data := make([]byte, size+1)
+	if _, err := io.ReadFull(r.stdout, data); err != nil {
+		return object{}, err
+	}
+	return object{oid: fields[0], data: data[:size]}, nil
+}
+
+func (r *Repo) startLocked() error {
+	if r.batch != nil {
+		return nil
+	}
+
+	cmd := exec.Command("git", "-C", r.Root, "cat-file", "--batch")
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	r.batch = cmd
+	r.stdin = stdin
+	r.stdout = bufio.NewReader(stdout)
+	return nil
+}
+
+func (r *Repo) stopLocked() error {
+	if r.batch == nil {
+		return nil
+	}
+
+	r.stdin.Close()
+	err := r.batch.Wait()
+	r.batch, r.stdin, r.stdout = nil, nil, nil
+	return err
+}
@@ -0,0 +1,213 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/cj3636/gdiff/internal/diff"
+)
+
+// spinnerFrames animate the status bar while git work runs in the background.
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+const spinnerInterval = 100 * time.Millisecond
+
+type spinnerTickMsg struct{}
+
+// diffLoadedMsg delivers the result of a background reloadDiff. seq ties it
+// to the load that produced it so superseded results can be dropped.
+type diffLoadedMsg struct {
+	seq       int
+	leftPath  string
+	rightPath string
+	result    *diff.DiffResult
+	blame     map[int]BlameLine
+	blameLeft map[int]BlameLine
+	err       error
+}
+
+type blameLoadedMsg struct {
+	seq       int
+	blame     map[int]BlameLine
+	blameLeft map[int]BlameLine
+	err       error
+}
+
+type historyLoadedMsg struct {
+	ref     string
+	skip    int
+	commits []Commit
+	err     error
+}
+
+type lineHistoryLoadedMsg struct {
+	label   string
+	entries []LineHistoryEntry
+	err     error
+}
+
+type refsLoadedMsg struct {
+	candidates []RefCandidate
+	err        error
+}
+
+func spinnerTick() tea.Cmd {
+	return tea.Tick(spinnerInterval, func(time.Time) tea.Msg { return spinnerTickMsg{} })
+}
+
+// startJob counts a background job and starts the status bar spinner unless
+// it is already turning.
+func (m *Model) startJob(job tea.Cmd) tea.Cmd {
+	m.jobs++
+	if m.spinning {
+		return job
+	}
+	m.spinning = true
+	return tea.Batch(job, spinnerTick())
+}
+
+func (m *Model) finishJob() {
+	if m.jobs > 0 {
+		m.jobs--
+	}
+}
+
+func (m *Model) advanceSpinner() tea.Cmd {
+	if m.jobs == 0 {
+		m.spinning = false
+		return nil
+	}
+	m.spinnerFrame = (m.spinnerFrame + 1) % len(spinnerFrames)
+	return spinnerTick()
+}
+
+func (m Model) spinnerView() string {
+	if m.jobs == 0 {
+		return ""
+	}
+	return spinnerFrames[m.spinnerFrame] + " working"
+}
+
+// reloadDiff recomputes the diff for the current refs in the background. A
+// load still in flight is cancelled and its result discarded.
+func (m *Model) reloadDiff() tea.Cmd {
+	if m.diffEngine == nil || !m.gitCtx.Enabled {
+		return nil
+	}
+
+	if m.cancelLoad != nil {
+		m.cancelLoad()
+	}
+	if m.cancelBlame != nil {
+		m.cancelBlame()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancelLoad = cancel
+	m.loadSeq++
+
+	seq, engine, g, withBlame := m.loadSeq, m.diffEngine, m.gitCtx, m.showBlame
+	return m.startJob(func() tea.Msg {
+		return loadDiff(ctx, seq, engine, g, withBlame)
+	})
+}
+
+func loadDiff(ctx context.Context, seq int, engine *diff.Engine, g GitContext, withBlame bool) diffLoadedMsg {
+	msg := diffLoadedMsg{seq: seq}
+	if len(g.RangePairs) > 0 {
+		pair := g.RangePairs[g.PairIndex]
+		msg.result = engine.DiffLines(pair.OldPatch, pair.NewPatch, pair.OldLabel(), pair.NewLabel())
+		return msg
+	}
+
+	msg.leftPath, msg.rightPath, msg.err = ResolvePaths(ctx, g.RepoRoot, g.FilePath, g.Ref1, g.Ref2)
+	if err := ctx.Err(); err != nil {
+		msg.err = err
+		return msg
+	}
+	if msg.err != nil {
+		return msg
+	}
+	if msg.leftPath == "" && msg.rightPath == "" {
+		msg.err = fmt.Errorf("%s does not exist at %s or %s", g.FilePath, g.Ref1, g.Ref2)
+		return msg
+	}
+
+	msg.result, msg.err = DiffSides(ctx, engine, g.RepoRoot, g.FilePath, g.Ref1, msg.leftPath, g.Ref2, msg.rightPath)
+	if msg.err != nil {
+		return msg
+	}
+
+	if withBlame {
+		g.LeftPath, g.RightPath = msg.leftPath, msg.rightPath
+		msg.blame, msg.blameLeft, _ = collectBlame(ctx, g)
+	}
+	msg.err = ctx.Err()
+	return msg
+}
+
+func (m *Model) applyDiffLoaded(msg diffLoadedMsg) tea.Cmd {
+	m.finishJob()
+	if msg.seq != m.loadSeq {
+		return nil
+	}
+
+	m.cancelLoad = nil
+	if msg.err != nil {
+		if !errors.Is(msg.err, context.Canceled) {
+			m.statusMessage = fmt.Sprintf("Unable to load diff: %v", msg.err)
+		}
+		return nil
+	}
+
+	if !m.rangeDiffMode() {
+		m.gitCtx.LeftPath, m.gitCtx.RightPath = msg.leftPath, msg.rightPath
+	}
+	m.diffResult = msg.result
+	m.renderedLines = msg.result.Lines
+	m.loading = false
+	m.viewport.selecting = false
+	m.gitCtx.Blame, m.gitCtx.BlameLeft = msg.blame, msg.blameLeft
+	m.moveCursor(0)
+	m.refreshPaletteEntries()
+
+	// Blame was switched on while the diff was loading without it.
+	if m.showBlame && m.gitCtx.Blame == nil {
+		return m.loadBlame()
+	}
+	return nil
+}
+
+// loadBlame fetches blame for the current diff in the background.
+func (m *Model) loadBlame() tea.Cmd {
+	if m.cancelBlame != nil {
+		m.cancelBlame()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancelBlame = cancel
+
+	seq, g := m.loadSeq, m.gitCtx
+	return m.startJob(func() tea.Msg {
+		blame, blameLeft, err := collectBlame(ctx, g)
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return blameLoadedMsg{seq: seq, blame: blame, blameLeft: blameLeft, err: err}
+	})
+}
+
+func (m *Model) applyBlameLoaded(msg blameLoadedMsg) {
+	m.finishJob()
+	if msg.seq != m.loadSeq || errors.Is(msg.err, context.Canceled) {
+		return
+	}
+
+	m.cancelBlame = nil
+	if msg.err != nil {
+		m.statusMessage = fmt.Sprintf("Blame unavailable: %v", msg.err)
+		return
+	}
+	m.gitCtx.Blame, m.gitCtx.BlameLeft = msg.blame, msg.blameLeft
+}
@@ -0,0 +1,163 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/cj3636/gdiff/internal/diff"
+)
+
+const (
+	blameAuthorWidth = 12
+	blameGutterWidth = 7 + 1 + blameAuthorWidth + 1 + 4 + 1
+)
+
+// heatBuckets are the age thresholds used to pick a heatmap colour, newest first.
+var heatBuckets = []time.Duration{
+	24 * time.Hour,
+	7 * 24 * time.Hour,
+	30 * 24 * time.Hour,
+	90 * 24 * time.Hour,
+	365 * 24 * time.Hour,
+}
+
+// blameForLine returns the right-side blame for lines present in the new
+// revision and the left-side blame for removed lines.
+func (m Model) blameForLine(line diff.DiffLine) (BlameLine, bool) {
+	if line.Type == diff.Removed {
+		entry, ok := m.gitCtx.BlameLeft[line.LineNo1]
+		return entry, ok
+	}
+	entry, ok := m.gitCtx.Blame[line.LineNo2]
+	return entry, ok
+}
+
+func (m Model) blameGutter(line diff.DiffLine) string {
+	if !m.showBlame || !m.gitCtx.Enabled {
+		return ""
+	}
+
+	entry, ok := m.blameForLine(line)
+	if !ok {
+		return fmt.Sprintf("%-*s", blameGutterWidth, "")
+	}
+
+	var text string
+	if entry.Uncommitted() {
+		text = fmt.Sprintf("%-7s %-*s %4s ", "·······", blameAuthorWidth, "uncommitted", "")
+	} else {
+		text = fmt.Sprintf("%-7s %-*s %4s ", entry.ShortCommit(), blameAuthorWidth,
+			truncate(entry.Author, blameAuthorWidth), relativeAge(entry.Time, time.Now()))
+	}
+
+	style := m.styles.blame
+	if m.config.BlameHeatmap && !entry.Uncommitted() {
+		style = lipgloss.NewStyle().Foreground(m.heatColor(entry.Time))
+	}
+	return style.Render(text)
+}
+
+// heatColor blends from the added colour for recent lines towards the line
+// number colour for old ones.
+func (m Model) heatColor(t time.Time) lipgloss.Color {
+	age := time.Since(t)
+	bucket := len(heatBuckets)
+	for i, limit := range heatBuckets {
+		if age < limit {
+			bucket = i
+			break
+		}
+	}
+
+	ratio := float64(bucket) / float64(len(heatBuckets))
+	return lipgloss.Color(blendHex(string(m.config.Theme.AddedFg), string(m.config.Theme.LineNumberFg), ratio))
+}
+
+func blendHex(from, to string, ratio float64) string {
+	var r1, g1, b1, r2, g2, b2 int
+	if _, err := fmt.Sscanf(from, "#%02x%02x%02x", &r1, &g1, &b1); err != nil {
+		return from
+	}
+	if _, err := fmt.Sscanf(to, "#%02x%02x%02x", &r2, &g2, &b2); err != nil {
+		return from
+	}
+
+	mix := func(a, b int) int {
+		return a + int(float64(b-a)*ratio)
+	}
+	return fmt.Sprintf("#%02x%02x%02x", mix(r1, r2), mix(g1, g2), mix(b1, b2))
+}
+
+func relativeAge(t, now time.Time) string {
+	d := now.Sub(t)
+	switch {
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	case d < 30*24*time.Hour:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	case d < 365*24*time.Hour:
+		return fmt.Sprintf("%dmo", int(d.Hours()/(24*30)))
+	default:
+		return fmt.Sprintf("%dy", int(d.Hours()/(24*365)))
+	}
+}
+
+// collectBlame loads blame for the right ref and, for removed lines, the left ref.
+func collectBlame(ctx context.Context, g GitContext) (map[int]BlameLine, map[int]BlameLine, error) {
+	if !g.Enabled {
+		return map[int]BlameLine{}, map[int]BlameLine{}, nil
+	}
+
+	right, err := LoadBlame(ctx, g.RepoRoot, g.RightPath, g.Ref2)
+	if err != nil {
+		return right, map[int]BlameLine{}, err
+	}
+	left, _ := LoadBlame(ctx, g.RepoRoot, g.LeftPath, g.Ref1)
+	return right, left, nil
+}
+
+func (m *Model) toggleBlame() tea.Cmd {
+	m.showBlame = !m.showBlame
+	if m.showBlame && m.gitCtx.Enabled && m.gitCtx.Blame == nil {
+		return m.loadBlame()
+	}
+	return nil
+}
+
+// openBlameCommit diffs the commit that last touched the cursor line against
+// its parent.
+func (m *Model) openBlameCommit() tea.Cmd {
+	if !m.showBlame || !m.gitCtx.Enabled {
+		return nil
+	}
+
+	lines := m.currentLines()
+	if m.viewport.cursor >= len(lines) {
+		return nil
+	}
+
+	entry, ok := m.blameForLine(lines[m.viewport.cursor])
+	switch {
+	case !ok:
+		m.statusMessage = "No blame information for this line"
+		return nil
+	case entry.Uncommitted():
+		m.statusMessage = "Line is not committed yet"
+		return nil
+	case entry.Previous == "":
+		m.statusMessage = fmt.Sprintf("%s is a root commit with no parent", entry.ShortCommit())
+		return nil
+	}
+
+	m.gitCtx.Ref1 = entry.Previous
+	m.gitCtx.Ref2 = entry.Commit
+	m.activeCommit = entry.Commit
+	m.scrollToTop()
+	m.statusMessage = fmt.Sprintf("Showing %s: %s", entry.ShortCommit(), truncate(entry.Summary, 50))
+	return m.reloadDiff()
+}
@@ -0,0 +1,105 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	blameRoot  = "1111111111111111111111111111111111111111"
+	blameChild = "2222222222222222222222222222222222222222"
+	blameNone  = "0000000000000000000000000000000000000000"
+)
+
+// blamePorcelain is git blame --porcelain output for a four line file: the
+// root commit wrote lines 1 and 3, its child line 2, and line 4 is not
+// committed. Commit metadata only follows the first line of each commit.
+var blamePorcelain = strings.Join([]string{
+	blameRoot + " 1 1 1",
+	"author Ada",
+	"author-mail <ada@example.com>",
+	"author-time 1700000000",
+	"author-tz +0000",
+	"summary Initial commit",
+	"boundary",
+	"filename f.txt",
+	"\tone",
+	blameChild + " 2 2 1",
+	"author Grace",
+	"author-time 1700086400",
+	"summary Change two",
+	"previous " + blameRoot + " f.txt",
+	"filename f.txt",
+	"\ttwo",
+	blameRoot + " 3 3 1",
+	"\tthree",
+	blameNone + " 4 4 1",
+	"author Not Committed Yet",
+	"author-time 1700172800",
+	"summary Version of f.txt from f.txt",
+	"filename f.txt",
+	"\tfour",
+	"",
+}, "\n")
+
+func TestParseBlamePorcelain(t *testing.T) {
+	blame := parseBlamePorcelain(blamePorcelain)
+
+	tests := []struct {
+		name        string
+		line        int
+		commit      string
+		author      string
+		summary     string
+		previous    string
+		time        int64
+		uncommitted bool
+	}{
+		{name: "first line of a commit", line: 1, commit: blameRoot, author: "Ada", summary: "Initial commit", time: 1700000000},
+		{name: "commit with a parent", line: 2, commit: blameChild, author: "Grace", summary: "Change two", previous: blameRoot, time: 1700086400},
+		{name: "commit seen before", line: 3, commit: blameRoot, author: "Ada", summary: "Initial commit", time: 1700000000},
+		{name: "uncommitted", line: 4, commit: blameNone, author: "Not Committed Yet", summary: "Version of f.txt from f.txt", time: 1700172800, uncommitted: true},
+	}
+
+	if len(blame) != len(tests) {
+		t.Fatalf("parsed %d lines, want %d", len(blame), len(tests))
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := blame[tt.line]
+			if !ok {
+				t.Fatalf("line %d has no blame", tt.line)
+			}
+			if got.Commit != tt.commit || got.Author != tt.author || got.Summary != tt.summary || got.Previous != tt.previous {
+				t.Errorf("line %d = %+v", tt.line, got)
+			}
+			if !got.Time.Equal(time.Unix(tt.time, 0)) {
+				t.Errorf("line %d time = %v, want %v", tt.line, got.Time, time.Unix(tt.time, 0))
+			}
+			if got.Uncommitted() != tt.uncommitted {
+				t.Errorf("line %d Uncommitted() = %v, want %v", tt.line, got.Uncommitted(), tt.uncommitted)
+			}
+		})
+	}
+}
+
+func TestParseBlamePorcelainMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+	}{
+		{name: "empty", raw: ""},
+		{name: "content before any header", raw: "\torphan\n"},
+		{name: "short hash", raw: "1111111 1 1 1\n\tline\n"},
+		{name: "header without line numbers", raw: blameRoot + "\n\tline\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if blame := parseBlamePorcelain(tt.raw); len(blame) != 0 {
+				t.Errorf("parseBlamePorcelain(%q) = %v, want no lines", tt.raw, blame)
+			}
+		})
+	}
+}
@@ -1,16 +1,594 @@
 package tui
 
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cj3636/gdiff/internal/diff"
+	"github.com/cj3636/gdiff/internal/export"
+	"github.com/cj3636/gdiff/internal/git"
+)
+
+// HistoryPageSize is the number of commits shown per history panel page.
+const HistoryPageSize = 8
+
 // GitContext carries git-related state for the TUI.
 type GitContext struct {
 	Enabled       bool
 	RepoRoot      string
 	FilePath      string
+	LeftPath      string
+	RightPath     string
 	Ref1          string
 	Ref2          string
 	Status        []string
 	Branches      []string
 	CurrentBranch string
-	CommitHistory []string
-	Blame         map[int]string
+	HistoryRef    string
+	ReviewBase    string
+	ReviewTarget  string
+	Files         []string
+	FileIndex     int
+	RangePairs    []RangePair
+	PairIndex     int
+	Submodule     string
+	CommitHistory []Commit
+	Blame         map[int]BlameLine
+	BlameLeft     map[int]BlameLine
 	ShowBlame     bool
 }
+
+// PatchPaths returns the repository-relative paths to name in patch
+// headers, with export.DevNull for a side where the file does not exist.
+// Outside git mode both are empty so the diff's own file names are used.
+func (g GitContext) PatchPaths() (string, string) {
+	if !g.Enabled || len(g.RangePairs) > 0 {
+		return "", ""
+	}
+	old, new := g.LeftPath, g.RightPath
+	if old == "" {
+		old = export.DevNull
+	}
+	if new == "" {
+		new = export.DevNull
+	}
+	return old, new
+}
+
+// Commit describes a single entry of a file's history.
+type Commit struct {
+	Hash      string
+	ShortHash string
+	Parents   []string
+	Author    string
+	Date      string
+	Subject   string
+	Body      string
+}
+
+const (
+	fieldSep  = "\x1f"
+	recordSep = "\x1e"
+)
+
+// LoadFileHistory returns up to limit commits touching relPath reachable
+// from ref, skipping the first skip entries. An empty relPath lists every
+// commit in ref, which may also be a range such as base..HEAD.
+func LoadFileHistory(ctx context.Context, repoRoot, relPath, ref string, skip, limit int) ([]Commit, error) {
+	repoRoot, ref = gitTarget(repoRoot, ref)
+	if git.IsWorktree(ref) {
+		ref = "HEAD"
+	}
+
+	format := strings.Join([]string{"%H", "%h", "%P", "%an", "%ad", "%s", "%b"}, fieldSep) + recordSep
+	args := []string{
+		"log",
+		"--format=" + format,
+		"--date=short",
+		"--skip=" + strconv.Itoa(skip),
+		"-n", strconv.Itoa(limit),
+	}
+	if relPath != "" {
+		args = append(args, "--follow", ref, "--", relPath)
+	} else {
+		args = append(args, ref)
+	}
+
+	out, err := git.Open(repoRoot).Run(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseCommits(string(out)), nil
+}
+
+func parseCommits(raw string) []Commit {
+	var commits []Commit
+	for _, record := range strings.Split(raw, recordSep) {
+		record = strings.TrimLeft(record, "\n")
+		if record == "" {
+			continue
+		}
+
+		fields := strings.SplitN(record, fieldSep, 7)
+		if len(fields) < 7 {
+			continue
+		}
+
+		commits = append(commits, Commit{
+			Hash:      fields[0],
+			ShortHash: fields[1],
+			Parents:   strings.Fields(fields[2]),
+			Author:    fields[3],
+			Date:      fields[4],
+			Subject:   fields[5],
+			Body:      strings.TrimSpace(fields[6]),
+		})
+	}
+	return commits
+}
+
+func gitOutputLines(ctx context.Context, repoRoot string, args ...string) ([]string, error) {
+	return git.Open(repoRoot).Lines(ctx, args...)
+}
+
+// DiffSides reads both sides of a diff in a single batch and diffs them. An
+// empty path means the file is missing on that side and reads as an empty
+// file. Each path's .gitattributes diff driver is honoured: textconv output
+// is compared instead of the raw content, binary files are summarised rather
+// than shown line by line, and the driver's xfuncname supplies hunk header
+// context.
+func DiffSides(ctx context.Context, engine *diff.Engine, repoRoot, relPath, leftRef, leftPath, rightRef, rightPath string) (*diff.DiffResult, error) {
+	leftLabel := fmt.Sprintf("%s:%s", leftRef, sidePath(leftPath, relPath))
+	rightLabel := fmt.Sprintf("%s:%s", rightRef, sidePath(rightPath, relPath))
+
+	repo := git.Open(repoRoot)
+	contents, err := repo.ReadFiles(ctx, []git.File{
+		{Rev: leftRef, Path: leftPath},
+		{Rev: rightRef, Path: rightPath},
+	})
+	if err != nil {
+		// A submodule has no content of its own; show its pointer instead.
+		change, subErr := LoadSubmoduleChange(ctx, repoRoot, sidePath(rightPath, leftPath), leftRef, rightRef)
+		if subErr != nil {
+			return nil, err
+		}
+		left, right := change.Lines()
+		return engine.DiffLines(left, right, leftLabel, rightLabel), nil
+	}
+
+	drivers, err := repo.Drivers(ctx, leftPath, rightPath)
+	if err != nil {
+		return nil, err
+	}
+	for i := range contents {
+		if contents[i], err = repo.Textconv(ctx, drivers[i], contents[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	if isBinary(drivers[0], contents[0]) || isBinary(drivers[1], contents[1]) {
+		result := engine.DiffLines(BinaryLines(leftPath, contents[0]), BinaryLines(rightPath, contents[1]), leftLabel, rightLabel)
+		result.Binary = true
+		return result, nil
+	}
+
+	result := engine.DiffLines(git.SplitLines(contents[0]), git.SplitLines(contents[1]), leftLabel, rightLabel)
+	result.File1NoEOL = diff.MissingFinalNewline(contents[0])
+	result.File2NoEOL = diff.MissingFinalNewline(contents[1])
+	driver := drivers[1]
+	if rightPath == "" {
+		driver = drivers[0]
+	}
+	result.FuncName = funcNameFor(driver)
+	return result, nil
+}
+
+// isBinary follows git: textconv output is always text, an unset diff
+// attribute or binary driver never is, and otherwise content is sniffed
+// unless the diff attribute is set.
+func isBinary(d git.Driver, data []byte) bool {
+	if d.Textconv != "" {
+		return false
+	}
+	return d.Binary || (!d.Text && git.IsBinary(data))
+}
+
+// BinaryLines stands in for a binary file with its size and blob id, so the
+// diff shows whether the two versions differ without dumping their bytes.
+func BinaryLines(path string, data []byte) []string {
+	if path == "" {
+		return []string{}
+	}
+	return []string{fmt.Sprintf("Binary file, %d bytes, blob %s", len(data), git.BlobID(data)[:7])}
+}
+
+func funcNameFor(d git.Driver) *diff.FuncName {
+	if d.XFuncName == "" {
+		return diff.DefaultFuncName
+	}
+	if f, err := diff.ParseFuncName(d.XFuncName); err == nil {
+		return f
+	}
+	return diff.DefaultFuncName
+}
+
+// BlameLine holds the parsed porcelain blame for a single line.
+type BlameLine struct {
+	Commit   string
+	Author   string
+	Time     time.Time
+	Summary  string
+	Previous string // parent revision the line came from, empty for root commits
+}
+
+// Uncommitted reports whether the line only exists in the working tree.
+func (b BlameLine) Uncommitted() bool {
+	return strings.Trim(b.Commit, "0") == ""
+}
+
+// ShortCommit returns the abbreviated commit hash.
+func (b BlameLine) ShortCommit() string {
+	if len(b.Commit) > 7 {
+		return b.Commit[:7]
+	}
+	return b.Commit
+}
+
+// LoadBlame runs git blame --porcelain for relPath at ref and returns the
+// entries keyed by line number on that side.
+func LoadBlame(ctx context.Context, repoRoot, relPath, ref string) (map[int]BlameLine, error) {
+	if relPath == "" {
+		return map[int]BlameLine{}, nil
+	}
+
+	repoRoot, ref = gitTarget(repoRoot, ref)
+	args := []string{"blame", "--porcelain"}
+	if !git.IsWorktree(ref) {
+		args = append(args, ref)
+	}
+	args = append(args, "--", relPath)
+
+	out, err := git.Open(repoRoot).Run(ctx, args...)
+	if err != nil {
+		return map[int]BlameLine{}, err
+	}
+	return parseBlamePorcelain(string(out)), nil
+}
+
+func parseBlamePorcelain(raw string) map[int]BlameLine {
+	blame := make(map[int]BlameLine)
+	commits := make(map[string]*BlameLine)
+
+	var current *BlameLine
+	finalLine := 0
+	for _, line := range strings.Split(raw, "\n") {
+		if strings.HasPrefix(line, "\t") {
+			if current != nil {
+				blame[finalLine] = *current
+			}
+			continue
+		}
+
+		key, value, _ := strings.Cut(line, " ")
+		if git.IsObjectID(key) {
+			fields := strings.Fields(value)
+			if len(fields) < 2 {
+				continue
+			}
+			finalLine, _ = strconv.Atoi(fields[1])
+			entry, ok := commits[key]
+			if !ok {
+				entry = &BlameLine{Commit: key}
+				commits[key] = entry
+			}
+			current = entry
+			continue
+		}
+
+		if current == nil {
+			continue
+		}
+
+		switch key {
+		case "author":
+			current.Author = value
+		case "author-time":
+			if secs, err := strconv.ParseInt(value, 10, 64); err == nil {
+				current.Time = time.Unix(secs, 0)
+			}
+		case "summary":
+			current.Summary = value
+		case "previous":
+			current.Previous, _, _ = strings.Cut(value, " ")
+		}
+	}
+
+	return blame
+}
+
+// ResolvePath returns the path relPath had at ref, following renames between
+// ref and the working tree the way git diff -M does. An empty result means the
+// file does not exist at ref; a ref that does not resolve is an error.
+func ResolvePath(ctx context.Context, repoRoot, relPath, ref string) (string, error) {
+	repo := git.Open(repoRoot)
+	if found, err := repo.Exists(ctx, ref, relPath); found || err != nil {
+		return relPath, err
+	}
+	if git.OnDisk(ref) {
+		return "", nil
+	}
+
+	for _, pair := range renamedPaths(ctx, repoRoot, ref) {
+		if pair[1] != relPath {
+			continue
+		}
+		if found, err := repo.Exists(ctx, ref, pair[0]); found || err != nil {
+			return pair[0], err
+		}
+	}
+	return "", nil
+}
+
+// ResolvePaths resolves relPath on both refs. When the file only exists on
+// one side, renames between the two refs are used to find the other path.
+func ResolvePaths(ctx context.Context, repoRoot, relPath, leftRef, rightRef string) (string, string, error) {
+	left, err := ResolvePath(ctx, repoRoot, relPath, leftRef)
+	if err != nil {
+		return "", "", err
+	}
+	right, err := ResolvePath(ctx, repoRoot, relPath, rightRef)
+	if err != nil {
+		return "", "", err
+	}
+	if (left == "") == (right == "") {
+		return left, right, nil
+	}
+
+	// git diff cannot see into another worktree, so renames are not followed.
+	if isLinkedWorktree(leftRef) || isLinkedWorktree(rightRef) {
+		return left, right, nil
+	}
+
+	var pairs [][2]string
+	switch {
+	case git.IsWorktree(leftRef):
+		pairs = renamedPaths(ctx, repoRoot, "-R", rightRef)
+	case git.IsWorktree(rightRef):
+		pairs = renamedPaths(ctx, repoRoot, leftRef)
+	default:
+		pairs = renamedPaths(ctx, repoRoot, leftRef, rightRef)
+	}
+
+	for _, pair := range pairs {
+		switch {
+		case left != "" && pair[0] == left:
+			right = pair[1]
+		case right != "" && pair[1] == right:
+			left = pair[0]
+		}
+	}
+	return left, right, nil
+}
+
+// wordPattern splits a ref into the words that may be object IDs.
+var wordPattern = regexp.MustCompile(`\w+`)
+
+// displayRef abbreviates the full object IDs in ref for display. Refs keep
+// them in full, as a short hash can become ambiguous while the TUI runs.
+func displayRef(ref string) string {
+	return wordPattern.ReplaceAllStringFunc(ref, func(word string) string {
+		if git.IsObjectID(word) {
+			return shortHash(word)
+		}
+		return word
+	})
+}
+
+// gitTarget maps a linked worktree revision onto that worktree's own
+// directory and working tree so commands such as blame and log run there.
+func gitTarget(repoRoot, ref string) (string, string) {
+	if dir, ok := git.LinkedWorktree(ref); ok {
+		return dir, git.Worktree
+	}
+	return repoRoot, ref
+}
+
+func isLinkedWorktree(ref string) bool {
+	_, ok := git.LinkedWorktree(ref)
+	return ok
+}
+
+// LinkedWorktree is another worktree attached to the same repository.
+type LinkedWorktree struct {
+	Path   string
+	Branch string
+}
+
+// LoadLinkedWorktrees lists the worktrees of the repository other than the
+// one at repoRoot.
+func LoadLinkedWorktrees(ctx context.Context, repoRoot string) ([]LinkedWorktree, error) {
+	lines, err := gitOutputLines(ctx, repoRoot, "worktree", "list", "--porcelain")
+	if err != nil {
+		return nil, err
+	}
+
+	self, _ := filepath.EvalSymlinks(repoRoot)
+	var worktrees []LinkedWorktree
+	for _, line := range append(lines, "") {
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "worktree":
+			worktrees = append(worktrees, LinkedWorktree{Path: value})
+		case "branch":
+			if len(worktrees) > 0 {
+				worktrees[len(worktrees)-1].Branch = strings.TrimPrefix(value, "refs/heads/")
+			}
+		case "detached":
+			if len(worktrees) > 0 {
+				worktrees[len(worktrees)-1].Branch = "(detached)"
+			}
+		}
+	}
+
+	linked := worktrees[:0]
+	for _, wt := range worktrees {
+		if resolved, _ := filepath.EvalSymlinks(wt.Path); resolved != self {
+			linked = append(linked, wt)
+		}
+	}
+	return linked, nil
+}
+
+// renamedPaths lists the old and new path of every rename or copy reported by
+// git diff -M for the given revisions.
+func renamedPaths(ctx context.Context, repoRoot string, revs ...string) [][2]string {
+	args := append([]string{"diff", "-M", "--name-status", "-z"}, revs...)
+	out, err := git.Open(repoRoot).Run(ctx, append(args, "--")...)
+	if err != nil {
+		return nil
+	}
+
+	var pairs [][2]string
+	fields := strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00")
+	for i := 0; i < len(fields); i++ {
+		status := fields[i]
+		if status == "" {
+			continue
+		}
+		if status[0] != 'R' && status[0] != 'C' {
+			i++
+			continue
+		}
+		if i+2 >= len(fields) {
+			break
+		}
+		pairs = append(pairs, [2]string{fields[i+1], fields[i+2]})
+		i += 2
+	}
+	return pairs
+}
+
+// ChangeSummary describes how the file differs between the two refs, or
+// returns an empty string for an in-place modification.
+func (g GitContext) ChangeSummary() string {
+	switch {
+	case g.LeftPath == "" && g.RightPath != "":
+		return "added"
+	case g.RightPath == "" && g.LeftPath != "":
+		return "deleted"
+	case g.LeftPath != g.RightPath:
+		return "renamed from " + g.LeftPath
+	default:
+		return ""
+	}
+}
+
+// committedLines maps lines start to end of the working tree copy of relPath
+// onto the lines of HEAD they are unchanged from, through a diff of the two.
+// Lines added since HEAD have no history and are left out of the range.
+func committedLines(ctx context.Context, engine *diff.Engine, repoRoot, relPath string, start, end int) (int, int, error) {
+	head, err := git.Open(repoRoot).ReadLines(ctx, "HEAD", relPath)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%s is not committed yet", relPath)
+	}
+	data, err := os.ReadFile(filepath.Join(repoRoot, relPath))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if engine == nil {
+		engine = diff.NewEngine(diff.EngineOptions{})
+	}
+	result := engine.DiffLines(head, git.SplitLines(data), "HEAD", relPath)
+	headStart, headEnd := 0, 0
+	for _, line := range result.Lines {
+		if line.Type != diff.Equal || line.LineNo2 < start || line.LineNo2 > end {
+			continue
+		}
+		if headStart == 0 {
+			headStart = line.LineNo1
+		}
+		headEnd = line.LineNo1
+	}
+	if headStart == 0 {
+		if start == end {
+			return 0, 0, fmt.Errorf("line %d has uncommitted changes", start)
+		}
+		return 0, 0, fmt.Errorf("lines %d-%d have uncommitted changes", start, end)
+	}
+	return headStart, headEnd, nil
+}
+
+// LineHistoryEntry is one commit that touched a tracked line range, together
+// with the range diff git log -L reported for it.
+type LineHistoryEntry struct {
+	Commit
+	Patch []string
+}
+
+// LoadLineHistory runs git log -L for the given line range of relPath,
+// starting at ref, and returns the commits newest first. Lines of a working
+// tree are first mapped onto HEAD, as git log only knows committed lines.
+func LoadLineHistory(ctx context.Context, engine *diff.Engine, repoRoot, relPath, ref string, start, end int) ([]LineHistoryEntry, error) {
+	repoRoot, ref = gitTarget(repoRoot, ref)
+	if git.IsWorktree(ref) {
+		var err error
+		if start, end, err = committedLines(ctx, engine, repoRoot, relPath, start, end); err != nil {
+			return nil, err
+		}
+		ref = "HEAD"
+	}
+
+	format := recordSep + strings.Join([]string{"%H", "%h", "%P", "%an", "%ad", "%s"}, fieldSep)
+	out, err := git.Open(repoRoot).Run(ctx,
+		"log",
+		"--format="+format,
+		"--date=short",
+		fmt.Sprintf("-L%d,%d:%s", start, end, relPath),
+		ref,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []LineHistoryEntry
+	for _, record := range strings.Split(string(out), recordSep) {
+		if strings.TrimSpace(record) == "" {
+			continue
+		}
+
+		header, patch, _ := strings.Cut(record, "\n")
+		fields := strings.SplitN(header, fieldSep, 6)
+		if len(fields) < 6 {
+			continue
+		}
+
+		entry := LineHistoryEntry{Commit: Commit{
+			Hash:      fields[0],
+			ShortHash: fields[1],
+			Parents:   strings.Fields(fields[2]),
+			Author:    fields[3],
+			Date:      fields[4],
+			Subject:   fields[5],
+		}}
+
+		inHunk := false
+		for _, line := range strings.Split(strings.TrimRight(patch, "\n"), "\n") {
+			if strings.HasPrefix(line, "@@") {
+				inHunk = true
+			}
+			if inHunk {
+				entry.Patch = append(entry.Patch, line)
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
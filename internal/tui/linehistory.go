@@ -0,0 +1,183 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/cj3636/gdiff/internal/diff"
+)
+
+const lineHistoryPatchRows = 11
+
+// lineHistoryTarget picks the ref, path and line range to trace for the
+// visual selection, or the cursor line when there is none. Removed lines
+// only exist on the left side, so a range made of nothing else is traced
+// there; otherwise the right side's lines are.
+func (m Model) lineHistoryTarget() (ref, path string, start, end int, ok bool) {
+	lines := m.currentLines()
+	first, last := m.viewport.cursor, m.viewport.cursor+1
+	if m.viewport.selecting {
+		first, last = m.selectionRange()
+	}
+	last = min(last, len(lines))
+	if first < 0 || first >= last {
+		return "", "", 0, 0, false
+	}
+
+	left := true
+	for _, line := range lines[first:last] {
+		left = left && line.Type == diff.Removed
+	}
+	for _, line := range lines[first:last] {
+		no := line.LineNo2
+		if left {
+			no = line.LineNo1
+		}
+		if no == 0 {
+			continue
+		}
+		if start == 0 {
+			start = no
+		}
+		end = no
+	}
+
+	if left {
+		return m.gitCtx.Ref1, m.gitCtx.LeftPath, start, end, start > 0
+	}
+	return m.gitCtx.Ref2, m.gitCtx.RightPath, start, end, start > 0
+}
+
+func (m *Model) openLineHistory() tea.Cmd {
+	if !m.gitCtx.Enabled {
+		m.statusMessage = "Git repository not detected - line history unavailable"
+		return nil
+	}
+
+	ref, path, start, end, ok := m.lineHistoryTarget()
+	if !ok || path == "" {
+		m.statusMessage = "No line under the cursor to trace"
+		return nil
+	}
+
+	repoRoot, engine := m.gitCtx.RepoRoot, m.diffEngine
+	label := fmt.Sprintf("%s:%d", path, start)
+	if end > start {
+		label = fmt.Sprintf("%s:%d-%d", path, start, end)
+	}
+	return m.startJob(func() tea.Msg {
+		entries, err := LoadLineHistory(context.Background(), engine, repoRoot, path, ref, start, end)
+		return lineHistoryLoadedMsg{label: label, entries: entries, err: err}
+	})
+}
+
+func (m *Model) applyLineHistoryLoaded(msg lineHistoryLoadedMsg) {
+	m.finishJob()
+	if msg.err != nil {
+		m.statusMessage = fmt.Sprintf("Line history unavailable: %v", msg.err)
+		return
+	}
+	if len(msg.entries) == 0 {
+		m.statusMessage = fmt.Sprintf("No commits touch %s", msg.label)
+		return
+	}
+
+	m.lineHistory = msg.entries
+	m.lineHistoryIndex = 0
+	m.lineHistoryTop = 0
+	m.lineHistoryLabel = msg.label
+	if m.activePanel != lineHistoryPanel {
+		m.togglePanel(lineHistoryPanel)
+	}
+}
+
+// handleLineHistoryInput steps through the traced commits. It reports whether
+// the key was consumed, along with any command it started.
+func (m *Model) handleLineHistoryInput(msg tea.KeyMsg) (bool, tea.Cmd) {
+	switch msg.String() {
+	case "left":
+		if m.lineHistoryIndex > 0 {
+			m.lineHistoryIndex--
+			m.lineHistoryTop = 0
+		}
+	case "right":
+		if m.lineHistoryIndex < len(m.lineHistory)-1 {
+			m.lineHistoryIndex++
+			m.lineHistoryTop = 0
+		}
+	case "up", "k":
+		if m.lineHistoryTop > 0 {
+			m.lineHistoryTop--
+		}
+	case "down", "j":
+		if len(m.lineHistory) > 0 && m.lineHistoryTop < len(m.lineHistory[m.lineHistoryIndex].Patch)-lineHistoryPatchRows {
+			m.lineHistoryTop++
+		}
+	case "enter":
+		return true, m.openLineHistoryCommit()
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+func (m *Model) openLineHistoryCommit() tea.Cmd {
+	if m.lineHistoryIndex >= len(m.lineHistory) {
+		return nil
+	}
+
+	entry := m.lineHistory[m.lineHistoryIndex]
+	if len(entry.Parents) == 0 {
+		m.statusMessage = fmt.Sprintf("%s is a root commit with no parent", entry.ShortHash)
+		return nil
+	}
+
+	m.gitCtx.Ref1 = entry.Hash + "^"
+	m.gitCtx.Ref2 = entry.Hash
+	m.activeCommit = entry.Hash
+	m.scrollToTop()
+	m.statusMessage = fmt.Sprintf("Showing %s: %s", entry.ShortHash, truncate(entry.Subject, 50))
+	return m.reloadDiff()
+}
+
+func (m Model) renderLineHistoryPanel() string {
+	if len(m.lineHistory) == 0 {
+		return m.styles.help.Render("No line history loaded")
+	}
+
+	entry := m.lineHistory[m.lineHistoryIndex]
+	lines := []string{
+		fmt.Sprintf("Line history: %s  (commit %d/%d)", m.lineHistoryLabel, m.lineHistoryIndex+1, len(m.lineHistory)),
+		"────────────",
+		m.styles.section.Render(fmt.Sprintf("commit %s  %s  %s  %s", entry.ShortHash, entry.Author, entry.Date, truncate(entry.Subject, 60))),
+	}
+
+	end := min(m.lineHistoryTop+lineHistoryPatchRows, len(entry.Patch))
+	for _, line := range entry.Patch[m.lineHistoryTop:end] {
+		style := m.styles.unchanged
+		switch {
+		case strings.HasPrefix(line, "+"):
+			style = m.styles.added
+		case strings.HasPrefix(line, "-"):
+			style = m.styles.removed
+		case strings.HasPrefix(line, "@@"):
+			style = m.styles.lineNumber.Copy().Width(0).Align(lipgloss.Left)
+		}
+		lines = append(lines, style.Render(truncate(line, max(m.width-6, 10))))
+	}
+	for i := end - m.lineHistoryTop; i < lineHistoryPatchRows; i++ {
+		lines = append(lines, "")
+	}
+
+	lines = append(lines, m.styles.help.Render("←/→ step commits  ↑/↓ scroll  enter: open commit"))
+
+	return m.styles.help.Copy().
+		BorderStyle(lipgloss.RoundedBorder()).
+		BorderForeground(m.config.Theme.BorderFg).
+		Padding(0, 1).
+		Width(m.width - 2).
+		Render(strings.Join(lines, "\n"))
+}
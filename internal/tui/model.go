@@ -1,9 +1,9 @@
 package tui
 
 import (
+	"context"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -41,6 +41,20 @@ type Model struct {
 	activePanel      panelType
 	gitCtx           GitContext
 	branchIndex      int
+	historyIndex     int
+	historyDone      bool
+	activeCommit     string
+	showPicker       bool
+	pickerSide       pickerSide
+	pickerQuery      string
+	pickerError      string
+	pickerIndex      int
+	pickerEntries    []RefCandidate
+	pickerMatches    []RefCandidate
+	lineHistory      []LineHistoryEntry
+	lineHistoryIndex int
+	lineHistoryTop   int
+	lineHistoryLabel string
 	paletteEntries   []paletteEntry
 	paletteIndex     int
 	settingsEntries  []settingsEntry
@@ -53,9 +67,24 @@ type Model struct {
 	minimapWidth     int
 	minimapStartCol  int
 	minimapHeight    int
+	historyLoading   bool
+	pickerLoading    bool
+	jobs             int
+	spinning         bool
+	spinnerFrame     int
+	loadSeq          int
+	cancelLoad       context.CancelFunc
+	cancelBlame      context.CancelFunc
+	submoduleParents []GitContext
+	redactor         *export.Redactor
 	statusMessage    string
+	chunkSize        int
+	loading          bool
+	loadProgress     float64
 }
 
+const chunkSize = 500
+
 type settingsEntry struct {
 	section     string
 	label       string
@@ -68,6 +97,7 @@ type settingsAction int
 const (
 	settingsActionTheme settingsAction = iota
 	settingsActionContrast
+	settingsActionBlameHeatmap
 	settingsActionLineNumbers
 	settingsActionLineNumberWidth
 	settingsActionLinePadding
@@ -102,6 +132,14 @@ const (
 	actionGoLine            = "go_line"
 	actionPrevBranch        = "prev_branch"
 	actionNextBranch        = "next_branch"
+	actionRefPicker         = "ref_picker"
+	actionOpenBlameCommit   = "open_blame_commit"
+	actionLineHistory       = "line_history"
+	actionNextFile          = "next_file"
+	actionPrevFile          = "prev_file"
+	actionToggleSubmodule   = "toggle_submodule"
+	actionVisualSelect      = "visual_select"
+	actionCopyHunk          = "copy_hunk"
 )
 
 type paletteEntry struct {
@@ -111,6 +149,8 @@ type paletteEntry struct {
 	action       paletteAction
 	offsetTarget int
 	format       export.Format
+	template     export.TemplateFile
+	scope        exportScope
 }
 
 type paletteAction int
@@ -130,9 +170,15 @@ const (
 	paletteActionJumpOffset
 	paletteActionCopyDiff
 	paletteActionSaveDiff
+	paletteActionPickLeftRef
+	paletteActionPickRightRef
+	paletteActionLineHistory
 )
 
+// diffChunkMsg carries the next rows of the diff the model started with.
+// Chunks are dropped once a background load has replaced that diff.
 type diffChunkMsg struct {
+	seq       int
 	lines     []diff.DiffLine
 	nextStart int
 	total     int
@@ -149,12 +195,17 @@ const (
 	statusPanel
 	branchPanel
 	historyPanel
+	lineHistoryPanel
 )
 
 // Viewport controls the visible portion of the diff
 type Viewport struct {
 	offset int // Current scroll position
 	height int // Available height for content
+	cursor int // Selected line, kept within the visible range
+	// A visual selection runs from anchor to cursor while selecting is set.
+	selecting bool
+	anchor    int
 }
 
 // Styles holds all the lipgloss styles
@@ -176,10 +227,10 @@ type Styles struct {
 	minimapDel lipgloss.Style
 }
 
-func loadDiffChunkCmd(lines []diff.DiffLine, start, size int) tea.Cmd {
+func loadDiffChunkCmd(seq int, lines []diff.DiffLine, start, size int) tea.Cmd {
 	return func() tea.Msg {
 		if start >= len(lines) {
-			return diffChunkMsg{done: true, progress: 1}
+			return diffChunkMsg{seq: seq, done: true, progress: 1}
 		}
 
 		end := start + size
@@ -192,6 +243,7 @@ func loadDiffChunkCmd(lines []diff.DiffLine, start, size int) tea.Cmd {
 
 		progress := float64(end) / float64(max(len(lines), 1))
 		return diffChunkMsg{
+			seq:       seq,
 			lines:     chunk,
 			nextStart: end,
 			total:     len(lines),
@@ -236,6 +288,7 @@ func NewModel(diffResult *diff.DiffResult, cfg *config.Config, engine *diff.Engi
 	}
 
 	if gitCtx.Enabled {
+		model.historyDone = len(gitCtx.CommitHistory) < HistoryPageSize
 		for i, b := range gitCtx.Branches {
 			if b == gitCtx.Ref2 {
 				model.branchIndex = i
@@ -255,6 +308,9 @@ func NewModel(diffResult *diff.DiffResult, cfg *config.Config, engine *diff.Engi
 		}
 	}
 
+	// Patterns are validated when the configuration is loaded.
+	model.redactor, _ = export.NewRedactor(cfg.RedactPatterns, cfg.RedactSecrets)
+
 	model.refreshPaletteEntries()
 	model.refreshSettingsEntries()
 	return model
@@ -323,7 +379,7 @@ func createStyles(cfg *config.Config) *Styles {
 // Init initializes the model
 func (m Model) Init() tea.Cmd {
 	if m.diffResult != nil && len(m.diffResult.Lines) > 0 {
-		return loadDiffChunkCmd(m.diffResult.Lines, 0, m.chunkSize)
+		return loadDiffChunkCmd(m.loadSeq, m.diffResult.Lines, 0, m.chunkSize)
 	}
 
 	return nil
@@ -333,6 +389,9 @@ func (m Model) Init() tea.Cmd {
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case diffChunkMsg:
+		if msg.seq != m.loadSeq {
+			return m, nil
+		}
 		m.renderedLines = append(m.renderedLines, msg.lines...)
 		m.loadProgress = msg.progress
 		m.loading = !msg.done
@@ -344,18 +403,36 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		} else {
 			m.statusMessage = fmt.Sprintf("Loading diff... %d%%", int(msg.progress*100))
-			return m, loadDiffChunkCmd(m.diffResult.Lines, msg.nextStart, m.chunkSize)
+			return m, loadDiffChunkCmd(msg.seq, m.diffResult.Lines, msg.nextStart, m.chunkSize)
 		}
 
+	case spinnerTickMsg:
+		return m, m.advanceSpinner()
+	case diffLoadedMsg:
+		return m, m.applyDiffLoaded(msg)
+	case blameLoadedMsg:
+		m.applyBlameLoaded(msg)
+	case historyLoadedMsg:
+		m.applyHistoryLoaded(msg)
+	case lineHistoryLoadedMsg:
+		m.applyLineHistoryLoaded(msg)
+	case refsLoadedMsg:
+		m.applyRefsLoaded(msg)
+	case submoduleLoadedMsg:
+		return m, m.applySubmoduleLoaded(msg)
+
 	case tea.KeyMsg:
 		if m.goToLineActive {
 			m.handleGoToLineInput(msg)
 			return m, nil
 		}
 
+		if m.showPicker {
+			return m, m.handlePickerInput(msg)
+		}
+
 		if m.showCommand {
-			m.handlePaletteInput(msg)
-			return m, nil
+			return m, m.handlePaletteInput(msg)
 		}
 
 		if m.showSettings {
@@ -363,6 +440,19 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, nil
 		}
 
+		if m.activePanel == historyPanel {
+			if handled, cmd := m.handleHistoryInput(msg); handled {
+				return m, cmd
+			}
+		}
+
+		if m.activePanel == lineHistoryPanel {
+			if handled, cmd := m.handleLineHistoryInput(msg); handled {
+				return m, cmd
+			}
+		}
+
+		var cmd tea.Cmd
 		switch {
 		case m.matchesKey(actionQuit, msg):
 			return m, tea.Quit
@@ -387,15 +477,22 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case m.matchesKey(actionToggleWrap, msg):
 			m.wrapLines = !m.wrapLines
 		case m.matchesKey(actionToggleBlame, msg):
-			m.showBlame = !m.showBlame
-			if m.showBlame && m.gitCtx.Enabled && m.gitCtx.Blame == nil {
-				m.gitCtx.Blame, m.err = m.collectBlame()
+			cmd = m.toggleBlame()
+		case m.viewport.selecting && msg.String() == "esc":
+			m.toggleSelection()
+		case m.matchesKey(actionVisualSelect, msg):
+			m.toggleSelection()
+		case msg.String() == "y":
+			if m.viewport.selecting {
+				m.copyDiff(export.FormatMarkdown, export.TemplateFile{}, scopeSelection)
+			} else {
+				m.copyDiff(export.FormatMarkdown, export.TemplateFile{}, scopeDiff)
 			}
-		case "y":
-			m.copyDiff(export.FormatMarkdown)
-		case "o":
-			m.saveDiff(export.FormatHTML)
-		case "<":
+		case m.matchesKey(actionCopyHunk, msg):
+			m.copyDiff(export.FormatMarkdown, export.TemplateFile{}, scopeHunk)
+		case msg.String() == "o":
+			m.saveDiff(export.FormatHTML, export.TemplateFile{}, scopeDiff)
+		case m.matchesKey(actionMinimapNarrow, msg):
 			m.adjustMinimapWidth(-2)
 		case m.matchesKey(actionMinimapWiden, msg):
 			m.adjustMinimapWidth(2)
@@ -418,10 +515,23 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case m.matchesKey(actionGoLine, msg):
 			m.openGoToLineDialog()
 		case m.matchesKey(actionPrevBranch, msg):
-			m.selectPreviousBranch()
+			cmd = m.selectPreviousBranch()
 		case m.matchesKey(actionNextBranch, msg):
-			m.selectNextBranch()
+			cmd = m.selectNextBranch()
+		case m.matchesKey(actionRefPicker, msg):
+			cmd = m.openRefPicker(pickerRight)
+		case m.matchesKey(actionOpenBlameCommit, msg):
+			cmd = m.openBlameCommit()
+		case m.matchesKey(actionLineHistory, msg):
+			cmd = m.openLineHistory()
+		case m.matchesKey(actionNextFile, msg):
+			cmd = m.selectFile(1)
+		case m.matchesKey(actionPrevFile, msg):
+			cmd = m.selectFile(-1)
+		case m.matchesKey(actionToggleSubmodule, msg):
+			cmd = m.toggleSubmodule()
 		}
+		return m, cmd
 
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
@@ -465,6 +575,10 @@ func (m Model) View() string {
 		sections = append(sections, m.renderSettingsModal())
 	}
 
+	if m.showPicker {
+		sections = append(sections, m.renderRefPicker())
+	}
+
 	if m.goToLineActive {
 		sections = append(sections, m.renderGoToLineDialog())
 	}
@@ -483,8 +597,18 @@ func (m Model) renderTitle() string {
 
 	if m.gitCtx.Enabled {
 		title = fmt.Sprintf("gdiff: %s (%s) ↔ %s (%s)",
-			truncate(m.diffResult.File1Name, 25), m.gitCtx.Ref1,
-			truncate(m.diffResult.File2Name, 25), m.gitCtx.Ref2)
+			truncate(m.diffResult.File1Name, 25), displayRef(m.gitCtx.Ref1),
+			truncate(m.diffResult.File2Name, 25), displayRef(m.gitCtx.Ref2))
+		if change := m.gitCtx.ChangeSummary(); change != "" {
+			title += " [" + change + "]"
+		}
+		if len(m.gitCtx.Files) > 1 {
+			title += fmt.Sprintf(" (file %d/%d)", m.gitCtx.FileIndex+1, len(m.gitCtx.Files))
+		}
+		if m.rangeDiffMode() {
+			title += fmt.Sprintf(" (pair %d/%d %s)", m.gitCtx.PairIndex+1, len(m.gitCtx.RangePairs),
+				m.gitCtx.RangePairs[m.gitCtx.PairIndex].Marker())
+		}
 	}
 	return m.styles.title.Render(title)
 }
@@ -539,6 +663,7 @@ func (m Model) renderUnifiedLines(start, end, contentWidth int, diffLines []diff
 
 	for i := start; i < end; i++ {
 		prefix, style, content, highlights := m.buildUnifiedLineParts(m.diffResult.Lines[i])
+		prefix = m.cursorMarker(i) + m.blameGutter(m.diffResult.Lines[i]) + prefix
 		available := contentWidth - lipgloss.Width(prefix)
 		if available < 10 {
 			available = 10
@@ -564,10 +689,10 @@ func (m Model) highlightStyleForLine(line diff.DiffLine) lipgloss.Style {
 	}
 }
 
-func (m Model) renderSideBySideLines(start, end, contentWidth int) []string {
+func (m Model) renderSideBySideLines(start, end, contentWidth int, diffLines []diff.DiffLine) []string {
 	var lines []string
 
-	columnWidth := (contentWidth - 3) / 2
+	columnWidth := (contentWidth - 4 - m.blameGutterWidth()) / 2
 	if columnWidth < 20 {
 		columnWidth = 20
 	}
@@ -575,12 +700,7 @@ func (m Model) renderSideBySideLines(start, end, contentWidth int) []string {
 	for i := start; i < end; i++ {
 		line := diffLines[i]
 		leftContent, rightContent := m.renderSideBySideLine(line, columnWidth)
-		combinedLine := leftContent + " │ " + rightContent
-		if m.showBlame && m.gitCtx.Enabled {
-			if blameText, ok := m.gitCtx.Blame[line.LineNo2]; ok && blameText != "" {
-				combinedLine += "  " + m.styles.blame.Render(truncate(blameText, 60))
-			}
-		}
+		combinedLine := m.cursorMarker(i) + m.blameGutter(line) + leftContent + " │ " + rightContent
 
 		lines = append(lines, truncateWidth(combinedLine, contentWidth))
 		for s := 0; s < m.config.Spacing.LineSpacing; s++ {
@@ -591,6 +711,23 @@ func (m Model) renderSideBySideLines(start, end, contentWidth int) []string {
 	return lines
 }
 
+func (m Model) cursorMarker(index int) string {
+	if index == m.viewport.cursor {
+		return m.styles.section.Render("▌")
+	}
+	if m.inSelection(index) {
+		return m.styles.section.Render("┃")
+	}
+	return " "
+}
+
+func (m Model) blameGutterWidth() int {
+	if !m.showBlame || !m.gitCtx.Enabled {
+		return 0
+	}
+	return blameGutterWidth
+}
+
 func (m Model) padLines(lines []string, target int) []string {
 	for len(lines) < target {
 		lines = append(lines, "")
@@ -712,6 +849,11 @@ func (m Model) buildUnifiedLineParts(line diff.DiffLine) (string, lipgloss.Style
 
 	content := symbol + " " + line.Content
 	displayHighlights := offsetHighlights(line.Highlights, runeLen(symbol+" "))
+	if marker, rest, ok := m.nestedMarker(line); ok {
+		parts = append(parts, style.Render(symbol+" ")+marker)
+		content = rest
+		displayHighlights = offsetHighlights(line.Highlights, -1)
+	}
 	return strings.Join(parts, ""), style, content, displayHighlights
 }
 
@@ -1009,6 +1151,27 @@ func (m Model) renderSideBySideLine(line diff.DiffLine, columnWidth int) (string
 		contentWidth = columnWidth - (m.lineNumberGutterWidth() + 1)
 	}
 
+	// Range-diff lines carry their own patch marker; keep it out of the
+	// highlighted content so it can be styled separately.
+	if marker, rest, ok := m.nestedMarker(line); ok {
+		leftPrefix, rightPrefix := "   ", "   "
+		switch line.Type {
+		case diff.Removed:
+			leftPrefix = leftStyle.Render("- ") + marker
+			leftContent, leftHighlights = rest, offsetHighlights(line.Highlights, -1)
+		case diff.Added:
+			rightPrefix = rightStyle.Render("+ ") + marker
+			rightContent, rightHighlights = rest, offsetHighlights(line.Highlights, -1)
+		case diff.Equal:
+			leftPrefix, rightPrefix = leftStyle.Render("  ")+marker, rightStyle.Render("  ")+marker
+			leftContent, rightContent = rest, rest
+			leftHighlights, rightHighlights = offsetHighlights(line.Highlights, -1), offsetHighlights(line.Highlights, -1)
+		}
+		leftParts = append(leftParts, leftPrefix)
+		rightParts = append(rightParts, rightPrefix)
+		contentWidth -= 3
+	}
+
 	// Ensure minimum width
 	if contentWidth < 10 {
 		contentWidth = 10
@@ -1085,10 +1248,8 @@ func (m Model) renderLine(line diff.DiffLine) string {
 	content := symbol + " " + line.Content
 	parts = append(parts, style.Render(content))
 
-	if m.showBlame && m.gitCtx.Enabled {
-		if blameText, ok := m.gitCtx.Blame[line.LineNo2]; ok && blameText != "" {
-			parts = append(parts, "  "+m.styles.blame.Render(truncate(blameText, 60)))
-		}
+	if gutter := m.blameGutter(line); gutter != "" {
+		parts = append([]string{gutter}, parts...)
 	}
 
 	return strings.Join(parts, "")
@@ -1128,16 +1289,24 @@ func (m Model) renderStatusBar() string {
 
 	gitInfo := ""
 	if m.gitCtx.Enabled {
-		gitInfo = fmt.Sprintf(" | git: %s→%s", m.gitCtx.Ref1, m.gitCtx.Ref2)
+		gitInfo = fmt.Sprintf(" | git: %s→%s", displayRef(m.gitCtx.Ref1), displayRef(m.gitCtx.Ref2))
 	}
 
 	status := fmt.Sprintf(
 		"Lines: +%d -%d =%d | Pos: %d/%d | View: %s | Wrap: %s | Color: %s | Theme: %s | Ln: %s | pad:%d space:%d%s | %s settings",
 		added, removed, unchanged,
-		m.viewport.offset+1, len(m.diffResult.Lines),
+		m.viewport.offset+1, totalLines,
 		viewMode, wrapMode, syntaxMode, themeLabel, lineNumbers, m.config.Spacing.LinePadding, m.config.Spacing.LineSpacing, gitInfo, m.keyDisplay(actionToggleSettings),
 	)
 
+	if m.diffResult != nil {
+		if fn := m.diffResult.FuncName.Context(m.currentLines(), m.viewport.cursor+1); fn != "" {
+			status = fmt.Sprintf("%s | @@ %s", status, fn)
+		}
+	}
+	if spinner := m.spinnerView(); spinner != "" {
+		status = fmt.Sprintf("%s | %s", status, spinner)
+	}
 	if m.statusMessage != "" {
 		status = fmt.Sprintf("%s | %s", status, m.statusMessage)
 	}
@@ -1188,6 +1357,8 @@ func (m Model) renderActivePanel() string {
 		return m.renderBranchesPanel()
 	case historyPanel:
 		return m.renderHistoryPanel()
+	case lineHistoryPanel:
+		return m.renderLineHistoryPanel()
 	default:
 		return ""
 	}
@@ -1203,9 +1374,10 @@ func (m Model) renderHelpPanel() string {
 		"  d         Half page down  │  s         Toggle stats     │  b    Toggle blame",
 		"  u         Half page up    │  y         Copy diff        │  o    Save diff (HTML)",
 		"  p         Command palette │  L         Go to line       │  g↵   Palette go-to-line",
-		"  w         Toggle wrapping │  S         Git status       │  B    Branch switcher",
-		"  H         Commit history  │  [ / ]     Cycle branches   │  < / > Resize minimap",
-		"  n / N     Next/prev change│  Mouse     Jump via minimap │  q    Quit",
+		"  w         Toggle wrapping │  S         Git status       │  B/R  Branches / ref picker",
+		"  H / l     File/line hist. │  [ / ]     Cycle branches   │  < / > Resize minimap",
+		"  n / N     Next/prev change│  { / }     Prev/next file   │  q    Quit",
+		"  M         Submodule in/out│  V         Select lines     │  Y    Copy hunk",
 		"",
 	}
 
@@ -1304,6 +1476,7 @@ func (m *Model) refreshSettingsEntries() {
 	m.settingsEntries = []settingsEntry{
 		{section: "Theme", label: "Preset", action: settingsActionTheme},
 		{section: "Theme", label: "High contrast", action: settingsActionContrast},
+		{section: "Theme", label: "Blame heatmap", action: settingsActionBlameHeatmap},
 		{section: "Layout", label: "Line numbers", action: settingsActionLineNumbers},
 		{section: "Layout", label: "Line number width", action: settingsActionLineNumberWidth},
 		{section: "Layout", label: "Line padding", action: settingsActionLinePadding},
@@ -1325,6 +1498,11 @@ func (m Model) settingDescription(entry settingsEntry) string {
 			return "On"
 		}
 		return "Off"
+	case settingsActionBlameHeatmap:
+		if m.config.BlameHeatmap {
+			return "On"
+		}
+		return "Off"
 	case settingsActionLineNumbers:
 		if m.config.ShowLineNo {
 			return "Shown"
@@ -1394,6 +1572,8 @@ func (m *Model) applySettingsAction(direction int) {
 	case settingsActionContrast:
 		m.config.HighContrast = !m.config.HighContrast
 		m.applyTheme()
+	case settingsActionBlameHeatmap:
+		m.config.BlameHeatmap = !m.config.BlameHeatmap
 	case settingsActionLineNumbers:
 		m.config.ShowLineNo = !m.config.ShowLineNo
 	case settingsActionLineNumberWidth:
@@ -1487,6 +1667,13 @@ func (m Model) renderGitStatusPanel() string {
 		return m.styles.help.Render("Git repository not detected - status unavailable")
 	}
 
+	if len(m.gitCtx.Files) > 0 {
+		return m.renderReviewFilesPanel()
+	}
+	if m.rangeDiffMode() {
+		return m.renderRangeDiffPanel()
+	}
+
 	if len(m.gitCtx.Status) == 0 {
 		return m.styles.help.Render("Working tree clean")
 	}
@@ -1500,6 +1687,37 @@ func (m Model) renderGitStatusPanel() string {
 		Render(strings.Join(content, "\n"))
 }
 
+func (m Model) renderReviewFilesPanel() string {
+	const visible = 12
+
+	title := fmt.Sprintf("Review: %s...%s  (%d files, %s/%s to switch)", m.gitCtx.ReviewTarget, displayRef(m.gitCtx.Ref2), len(m.gitCtx.Files),
+		m.keyDisplay(actionPrevFile), m.keyDisplay(actionNextFile))
+	if m.gitCtx.Submodule != "" {
+		title = fmt.Sprintf("Submodule %s: %s..%s  (%d files, %s/%s to switch, %s to leave)", m.gitCtx.Submodule, displayRef(m.gitCtx.Ref1), displayRef(m.gitCtx.Ref2),
+			len(m.gitCtx.Files), m.keyDisplay(actionPrevFile), m.keyDisplay(actionNextFile), m.keyDisplay(actionToggleSubmodule))
+	}
+	lines := []string{title, "─────────"}
+
+	start := max(0, m.gitCtx.FileIndex-visible/2)
+	end := min(start+visible, len(m.gitCtx.Files))
+	for i := start; i < end; i++ {
+		label := m.gitCtx.Files[i]
+		if i == m.gitCtx.FileIndex {
+			label = m.styles.selection.Render("> " + label)
+		} else {
+			label = "  " + label
+		}
+		lines = append(lines, label)
+	}
+
+	return m.styles.help.Copy().
+		BorderStyle(lipgloss.RoundedBorder()).
+		BorderForeground(m.config.Theme.BorderFg).
+		Padding(0, 1).
+		Width(m.width - 2).
+		Render(strings.Join(lines, "\n"))
+}
+
 func (m Model) renderBranchesPanel() string {
 	if !m.gitCtx.Enabled {
 		return m.styles.help.Render("Git repository not detected - branches unavailable")
@@ -1532,8 +1750,43 @@ func (m Model) renderHistoryPanel() string {
 		return m.styles.help.Render("Git repository not detected - history unavailable")
 	}
 
-	lines := []string{"Recent Commits", "────────────"}
-	lines = append(lines, m.gitCtx.CommitHistory...)
+	page := m.historyIndex / HistoryPageSize
+	lines := []string{
+		fmt.Sprintf("History: %s  (page %d)", m.historyTitle(), page+1),
+		"────────────",
+	}
+
+	if len(m.gitCtx.CommitHistory) == 0 {
+		lines = append(lines, "No commits touch this file")
+	} else {
+		selected := m.gitCtx.CommitHistory[m.historyIndex]
+		lines = append(lines, m.styles.section.Render(fmt.Sprintf("commit %s  %s  %s", selected.ShortHash, selected.Author, selected.Date)))
+		lines = append(lines, "  "+selected.Subject)
+		body := ""
+		if selected.Body != "" {
+			body = "  " + truncate(strings.SplitN(selected.Body, "\n", 2)[0], max(m.width-8, 10))
+		}
+		lines = append(lines, body, "")
+
+		start := page * HistoryPageSize
+		end := min(start+HistoryPageSize, len(m.gitCtx.CommitHistory))
+		for i := start; i < end; i++ {
+			commit := m.gitCtx.CommitHistory[i]
+			marker := " "
+			if commit.Hash == m.activeCommit {
+				marker = "*"
+			}
+			label := fmt.Sprintf("%s %s %s  %s", marker, commit.ShortHash, commit.Date, truncate(commit.Subject, 60))
+			if i == m.historyIndex {
+				label = m.styles.selection.Render("> " + label)
+			} else {
+				label = "  " + label
+			}
+			lines = append(lines, label)
+		}
+	}
+
+	lines = append(lines, m.styles.help.Render(fmt.Sprintf("↑/↓ select  ←/→ page  enter: vs parent  r: vs %s", displayRef(m.gitCtx.Ref2))))
 
 	return m.styles.help.Copy().
 		BorderStyle(lipgloss.RoundedBorder()).
@@ -1543,6 +1796,120 @@ func (m Model) renderHistoryPanel() string {
 		Render(strings.Join(lines, "\n"))
 }
 
+func (m Model) historyTitle() string {
+	if m.gitCtx.ReviewBase != "" {
+		return displayRef(m.gitCtx.HistoryRef)
+	}
+	return m.gitCtx.FilePath
+}
+
+// handleHistoryInput navigates the history panel. It reports whether the key
+// was consumed so unrelated keys keep their global bindings, along with any
+// command it started.
+func (m *Model) handleHistoryInput(msg tea.KeyMsg) (bool, tea.Cmd) {
+	switch msg.String() {
+	case "up", "k":
+		return true, m.moveHistorySelection(-1)
+	case "down", "j":
+		return true, m.moveHistorySelection(1)
+	case "left", "pgup":
+		return true, m.moveHistorySelection(-HistoryPageSize)
+	case "right", "pgdown":
+		return true, m.moveHistorySelection(HistoryPageSize)
+	case "enter":
+		return true, m.openHistoryCommit(false)
+	case "r":
+		return true, m.openHistoryCommit(true)
+	default:
+		return false, nil
+	}
+}
+
+// moveHistorySelection moves the selection, fetching the next page in the
+// background when it runs past the loaded commits.
+func (m *Model) moveHistorySelection(delta int) tea.Cmd {
+	var cmd tea.Cmd
+	target := m.historyIndex + delta
+	if target >= len(m.gitCtx.CommitHistory) {
+		cmd = m.loadMoreHistory()
+	}
+
+	if target >= len(m.gitCtx.CommitHistory) {
+		target = len(m.gitCtx.CommitHistory) - 1
+	}
+	if target < 0 {
+		target = 0
+	}
+	m.historyIndex = target
+	return cmd
+}
+
+// historyPath limits the history panel to the current file, except in review
+// mode where it lists every commit on the branch.
+func (m Model) historyPath() string {
+	if m.gitCtx.ReviewBase != "" {
+		return ""
+	}
+	return m.gitCtx.FilePath
+}
+
+func (m *Model) loadMoreHistory() tea.Cmd {
+	if m.historyDone || m.historyLoading || !m.gitCtx.Enabled {
+		return nil
+	}
+
+	m.historyLoading = true
+	repoRoot, path, ref, skip := m.gitCtx.RepoRoot, m.historyPath(), m.gitCtx.HistoryRef, len(m.gitCtx.CommitHistory)
+	return m.startJob(func() tea.Msg {
+		commits, err := LoadFileHistory(context.Background(), repoRoot, path, ref, skip, HistoryPageSize)
+		return historyLoadedMsg{ref: ref, skip: skip, commits: commits, err: err}
+	})
+}
+
+func (m *Model) applyHistoryLoaded(msg historyLoadedMsg) {
+	m.finishJob()
+	m.historyLoading = false
+	if msg.ref != m.gitCtx.HistoryRef || msg.skip != len(m.gitCtx.CommitHistory) {
+		return
+	}
+
+	if msg.err != nil {
+		m.statusMessage = fmt.Sprintf("History unavailable: %v", msg.err)
+		m.historyDone = true
+		return
+	}
+
+	if len(msg.commits) < HistoryPageSize {
+		m.historyDone = true
+	}
+	m.gitCtx.CommitHistory = append(m.gitCtx.CommitHistory, msg.commits...)
+}
+
+// openHistoryCommit diffs the selected commit against its parent, or against
+// the current right-hand ref when againstRef2 is set.
+func (m *Model) openHistoryCommit(againstRef2 bool) tea.Cmd {
+	if m.historyIndex >= len(m.gitCtx.CommitHistory) {
+		return nil
+	}
+
+	commit := m.gitCtx.CommitHistory[m.historyIndex]
+	if againstRef2 {
+		m.gitCtx.Ref1 = commit.Hash
+	} else {
+		if len(commit.Parents) == 0 {
+			m.statusMessage = fmt.Sprintf("%s is a root commit with no parent", commit.ShortHash)
+			return nil
+		}
+		m.gitCtx.Ref1 = commit.Hash + "^"
+		m.gitCtx.Ref2 = commit.Hash
+	}
+
+	m.activeCommit = commit.Hash
+	m.scrollToTop()
+	m.statusMessage = fmt.Sprintf("Showing %s: %s", commit.ShortHash, truncate(commit.Subject, 50))
+	return m.reloadDiff()
+}
+
 func (m *Model) toggleCommandPalette() {
 	m.showCommand = !m.showCommand
 	m.activePanel = noPanel
@@ -1551,7 +1918,7 @@ func (m *Model) toggleCommandPalette() {
 	m.updateViewportHeight()
 }
 
-func (m *Model) handlePaletteInput(msg tea.KeyMsg) {
+func (m *Model) handlePaletteInput(msg tea.KeyMsg) tea.Cmd {
 	switch msg.String() {
 	case "esc", "q":
 		m.showCommand = false
@@ -1561,8 +1928,9 @@ func (m *Model) handlePaletteInput(msg tea.KeyMsg) {
 	case "down", "j":
 		m.movePaletteSelection(1)
 	case "enter", " ":
-		m.executePaletteSelection()
+		return m.executePaletteSelection()
 	}
+	return nil
 }
 
 func (m *Model) movePaletteSelection(delta int) {
@@ -1578,11 +1946,12 @@ func (m *Model) movePaletteSelection(delta int) {
 	}
 }
 
-func (m *Model) executePaletteSelection() {
+func (m *Model) executePaletteSelection() tea.Cmd {
 	if len(m.paletteEntries) == 0 {
-		return
+		return nil
 	}
 
+	var cmd tea.Cmd
 	entry := m.paletteEntries[m.paletteIndex]
 	switch entry.action {
 	case paletteActionToggleHelp:
@@ -1594,10 +1963,7 @@ func (m *Model) executePaletteSelection() {
 	case paletteActionToggleSyntax:
 		m.syntaxHighlight = !m.syntaxHighlight
 	case paletteActionToggleBlame:
-		m.showBlame = !m.showBlame
-		if m.showBlame && m.gitCtx.Enabled && m.gitCtx.Blame == nil {
-			m.gitCtx.Blame, m.err = m.collectBlame()
-		}
+		cmd = m.toggleBlame()
 	case paletteActionToggleWrap:
 		m.wrapLines = !m.wrapLines
 	case paletteActionOpenSettings:
@@ -1611,9 +1977,15 @@ func (m *Model) executePaletteSelection() {
 	case paletteActionJumpOffset:
 		m.jumpToOffset(entry.offsetTarget)
 	case paletteActionCopyDiff:
-		m.copyDiff(entry.format)
+		m.copyDiff(entry.format, entry.template, entry.scope)
 	case paletteActionSaveDiff:
-		m.saveDiff(entry.format)
+		m.saveDiff(entry.format, entry.template, entry.scope)
+	case paletteActionPickLeftRef:
+		cmd = m.openRefPicker(pickerLeft)
+	case paletteActionPickRightRef:
+		cmd = m.openRefPicker(pickerRight)
+	case paletteActionLineHistory:
+		cmd = m.openLineHistory()
 	}
 
 	if entry.action != paletteActionGoToLine {
@@ -1621,6 +1993,7 @@ func (m *Model) executePaletteSelection() {
 	}
 	m.refreshPaletteEntries()
 	m.updateViewportHeight()
+	return cmd
 }
 
 func (m *Model) refreshPaletteEntries() {
@@ -1637,10 +2010,38 @@ func (m *Model) refreshPaletteEntries() {
 		paletteEntry{section: "Commands", label: "Go to top", description: "g", action: paletteActionGoTop},
 		paletteEntry{section: "Commands", label: "Go to bottom", description: "G", action: paletteActionGoBottom},
 		paletteEntry{section: "Commands", label: "Go to line", description: "L", action: paletteActionGoToLine},
+		paletteEntry{section: "Git", label: "Pick left ref", description: "branches, tags, commits, stashes", action: paletteActionPickLeftRef},
+		paletteEntry{section: "Git", label: "Pick right ref", description: m.keyDisplay(actionRefPicker), action: paletteActionPickRightRef},
+		paletteEntry{section: "Git", label: "Line history", description: m.keyDisplay(actionLineHistory), action: paletteActionLineHistory},
 		paletteEntry{section: "Export", label: "Copy diff (Markdown)", description: "y", action: paletteActionCopyDiff, format: export.FormatMarkdown},
+		paletteEntry{section: "Export", label: "Copy diff (Markdown HTML)", description: "command palette", action: paletteActionCopyDiff, format: export.FormatMarkdownHTML},
 		paletteEntry{section: "Export", label: "Copy diff (ANSI)", description: "command palette", action: paletteActionCopyDiff, format: export.FormatANSI},
 		paletteEntry{section: "Export", label: "Save diff (HTML)", description: "o", action: paletteActionSaveDiff, format: export.FormatHTML},
+		paletteEntry{section: "Export", label: "Copy diff (Patch)", description: "command palette", action: paletteActionCopyDiff, format: export.FormatPatch},
+		paletteEntry{section: "Export", label: "Save diff (Patch)", description: "command palette", action: paletteActionSaveDiff, format: export.FormatPatch},
+		paletteEntry{section: "Export", label: "Save diff (JSON)", description: "command palette", action: paletteActionSaveDiff, format: export.FormatJSON},
+		paletteEntry{section: "Export", label: "Save view (SVG)", description: "visible lines as an image", action: paletteActionSaveDiff, format: export.FormatSVG},
+		paletteEntry{section: "Export", label: "Copy hunk (Markdown)", description: m.keyDisplay(actionCopyHunk), action: paletteActionCopyDiff, format: export.FormatMarkdown, scope: scopeHunk},
+		paletteEntry{section: "Export", label: "Copy hunk (Patch)", description: "hunk under the cursor", action: paletteActionCopyDiff, format: export.FormatPatch, scope: scopeHunk},
+		paletteEntry{section: "Export", label: "Save hunk (Patch)", description: "hunk under the cursor", action: paletteActionSaveDiff, format: export.FormatPatch, scope: scopeHunk},
 	)
+	if m.viewport.selecting {
+		entries = append(entries,
+			paletteEntry{section: "Export", label: "Copy selection (Markdown)", description: "y", action: paletteActionCopyDiff, format: export.FormatMarkdown, scope: scopeSelection},
+			paletteEntry{section: "Export", label: "Copy selection (Patch)", description: "only the selected changes", action: paletteActionCopyDiff, format: export.FormatPatch, scope: scopeSelection},
+			paletteEntry{section: "Export", label: "Copy selection (ANSI)", description: "command palette", action: paletteActionCopyDiff, format: export.FormatANSI, scope: scopeSelection},
+			paletteEntry{section: "Export", label: "Save selection (Patch)", description: "only the selected changes", action: paletteActionSaveDiff, format: export.FormatPatch, scope: scopeSelection},
+			paletteEntry{section: "Export", label: "Save selection (SVG)", description: "selected lines as an image", action: paletteActionSaveDiff, format: export.FormatSVG, scope: scopeSelection},
+		)
+	}
+
+	templates, _ := export.ListTemplates(m.config.TemplateDir)
+	for _, t := range templates {
+		entries = append(entries,
+			paletteEntry{section: "Export", label: fmt.Sprintf("Copy diff (%s template)", t.Name), description: t.Path, action: paletteActionCopyDiff, format: export.FormatTemplate, template: t},
+			paletteEntry{section: "Export", label: fmt.Sprintf("Save diff (%s template)", t.Name), description: t.Path, action: paletteActionSaveDiff, format: export.FormatTemplate, template: t},
+		)
+	}
 
 	for _, offset := range m.changeOffsets() {
 		lines := m.currentLines()
@@ -1676,58 +2077,110 @@ func (m *Model) refreshPaletteEntries() {
 	}
 }
 
-func (m *Model) copyDiff(format export.Format) {
+func (m *Model) copyDiff(format export.Format, tmpl export.TemplateFile, scope exportScope) {
 	if m.diffResult == nil {
 		return
 	}
 
-	content := m.exportDiff(format)
+	content, redacted := m.exportDiff(format, tmpl, scope)
 	if content == "" {
 		return
 	}
 
-	if err := export.CopyToClipboard(content, os.Stdout); err != nil {
+	copied, err := export.CopyToClipboard(content, os.Stdout)
+	if err != nil {
 		m.err = err
 		return
 	}
 
-	m.statusMessage = fmt.Sprintf("Copied %s diff to clipboard", formatLabel(format))
+	verb := "Copied"
+	if !copied.Confirmed {
+		// Nothing answers an OSC52 sequence, so it may not have landed.
+		verb = "Sent"
+	}
+	m.statusMessage = fmt.Sprintf("%s %s %s to clipboard via %s", verb, formatLabel(format, tmpl), scope.noun(), copied.Backend) + redactedNote(redacted)
+	if scope == scopeSelection {
+		m.viewport.selecting = false
+	}
 }
 
-func (m *Model) saveDiff(format export.Format) {
+func (m *Model) saveDiff(format export.Format, tmpl export.TemplateFile, scope exportScope) {
 	if m.diffResult == nil {
 		return
 	}
 
-	content := m.exportDiff(format)
+	content, redacted := m.exportDiff(format, tmpl, scope)
 	if content == "" {
 		return
 	}
 
-	filename := m.defaultExportFilename(format)
+	filename := m.defaultExportFilename(format, tmpl)
 	if err := os.WriteFile(filename, []byte(content), 0o644); err != nil {
 		m.err = err
 		return
 	}
 
-	m.statusMessage = fmt.Sprintf("Saved %s diff to %s", formatLabel(format), filename)
+	m.statusMessage = fmt.Sprintf("Saved %s %s to %s", formatLabel(format, tmpl), scope.noun(), filename) + redactedNote(redacted)
+	if scope == scopeSelection {
+		m.viewport.selecting = false
+	}
+}
+
+// redactedNote warns that an export had secrets masked.
+func redactedNote(count int) string {
+	if count == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" ⚠ redacted %d secrets", count)
 }
 
-func (m *Model) exportDiff(format export.Format) string {
+// exportDiff renders the part of the diff that scope covers with secrets
+// masked, returning the number of secrets that were. A scope that cannot be
+// exported, such as a selection without changes for a patch, is reported in
+// the status bar.
+func (m *Model) exportDiff(format export.Format, tmpl export.TemplateFile, scope exportScope) (string, int) {
 	if format == "" {
 		format = export.FormatMarkdown
 	}
 
-	content, err := export.Render(m.diffResult, format, export.Options{
-		Title:           m.exportTitle(),
-		ShowLineNumbers: m.config.ShowLineNo,
-	})
+	opts, start, end, err := m.scopeOptions(scope)
 	if err != nil {
-		m.err = err
-		return ""
+		m.statusMessage = fmt.Sprintf("Cannot export %s: %v", scope.noun(), err)
+		return "", 0
+	}
+
+	result, redacted, err := m.redactor.ResultFor(m.diffResult, format)
+	if err != nil {
+		m.statusMessage = fmt.Sprintf("Cannot export %s: %v", scope.noun(), err)
+		return "", 0
+	}
+	if format == export.FormatSVG {
+		snap := *m
+		snap.diffResult = result
+		snap.viewport.selecting = false
+		opts.View = snap.snapshot(start, end)
+		opts.Lines, opts.Hunks = nil, nil
+	}
+
+	opts.Title = m.exportTitle()
+	opts.ShowLineNumbers = m.config.ShowLineNo
+	opts.Context = m.config.ContextLines
+	opts.OldPath, opts.NewPath = m.gitCtx.PatchPaths()
+	opts.SideBySide = m.sideBySideMode
+	opts.Theme = &m.config.Theme
+	opts.Colors = export.DetectColorDepth()
+	opts.Template = tmpl.Path
+	content, err := export.Render(result, format, opts)
+	if err != nil {
+		if scope != scopeDiff {
+			m.statusMessage = fmt.Sprintf("Cannot export %s: %v", scope.noun(), err)
+		} else {
+			m.err = err
+		}
+		return "", 0
 	}
 
-	return content
+	return content, redacted
 }
 
 func (m Model) exportTitle() string {
@@ -1737,15 +2190,23 @@ func (m Model) exportTitle() string {
 	return fmt.Sprintf("%s ↔ %s", m.diffResult.File1Name, m.diffResult.File2Name)
 }
 
-func (m Model) defaultExportFilename(format export.Format) string {
+func (m Model) defaultExportFilename(format export.Format, tmpl export.TemplateFile) string {
 	ext := "txt"
 	switch format {
 	case export.FormatHTML:
 		ext = "html"
-	case export.FormatMarkdown:
+	case export.FormatMarkdown, export.FormatMarkdownHTML:
 		ext = "md"
 	case export.FormatANSI:
 		ext = "txt"
+	case export.FormatPatch:
+		ext = "patch"
+	case export.FormatJSON:
+		ext = "json"
+	case export.FormatSVG:
+		ext = "svg"
+	case export.FormatTemplate:
+		ext = tmpl.Extension()
 	}
 
 	left := sanitizeFilename(filepath.Base(m.diffResult.File1Name))
@@ -1773,12 +2234,22 @@ func sanitizeFilename(name string) string {
 	return strings.Trim(cleaned, " ")
 }
 
-func formatLabel(f export.Format) string {
+func formatLabel(f export.Format, tmpl export.TemplateFile) string {
 	switch f {
+	case export.FormatTemplate:
+		return tmpl.Name
 	case export.FormatHTML:
 		return "HTML"
 	case export.FormatANSI:
 		return "ANSI"
+	case export.FormatPatch:
+		return "Patch"
+	case export.FormatMarkdownHTML:
+		return "Markdown HTML"
+	case export.FormatJSON:
+		return "JSON"
+	case export.FormatSVG:
+		return "SVG"
 	default:
 		return "Markdown"
 	}
@@ -1912,6 +2383,7 @@ func (m *Model) jumpToOffset(offset int) {
 	if m.diffResult == nil {
 		return
 	}
+	m.viewport.cursor = max(0, min(offset, len(m.currentLines())-1))
 	maxOffset := max(0, len(m.currentLines())-m.viewport.height)
 	if offset < 0 {
 		offset = 0
@@ -1922,18 +2394,26 @@ func (m *Model) jumpToOffset(offset int) {
 	m.viewport.offset = offset
 }
 
+// moveCursor moves the selected line and scrolls just enough to keep it visible.
+func (m *Model) moveCursor(delta int) {
+	total := len(m.currentLines())
+	m.viewport.cursor = max(0, min(m.viewport.cursor+delta, total-1))
+
+	if m.viewport.cursor < m.viewport.offset {
+		m.viewport.offset = m.viewport.cursor
+	}
+	if m.viewport.cursor >= m.viewport.offset+m.viewport.height {
+		m.viewport.offset = m.viewport.cursor - m.viewport.height + 1
+	}
+}
+
 // Scroll functions
 func (m *Model) scrollDown() {
-	maxOffset := max(0, len(m.currentLines())-m.viewport.height)
-	if m.viewport.offset < maxOffset {
-		m.viewport.offset++
-	}
+	m.moveCursor(1)
 }
 
 func (m *Model) scrollUp() {
-	if m.viewport.offset > 0 {
-		m.viewport.offset--
-	}
+	m.moveCursor(-1)
 }
 
 func (m *Model) scrollPageDown() {
@@ -1946,6 +2426,7 @@ func (m *Model) scrollPageDown() {
 	if m.viewport.offset > maxOffset {
 		m.viewport.offset = maxOffset
 	}
+	m.moveCursor(halfPage)
 }
 
 func (m *Model) scrollPageUp() {
@@ -1957,14 +2438,17 @@ func (m *Model) scrollPageUp() {
 	if m.viewport.offset < 0 {
 		m.viewport.offset = 0
 	}
+	m.moveCursor(-halfPage)
 }
 
 func (m *Model) scrollToTop() {
 	m.viewport.offset = 0
+	m.viewport.cursor = 0
 }
 
 func (m *Model) scrollToBottom() {
 	m.viewport.offset = max(0, len(m.currentLines())-m.viewport.height)
+	m.viewport.cursor = max(0, len(m.currentLines())-1)
 }
 
 func (m *Model) togglePanel(target panelType) {
@@ -1981,104 +2465,51 @@ func (m *Model) togglePanel(target panelType) {
 	m.updateViewportHeight()
 }
 
-func (m *Model) selectNextBranch() {
+func (m *Model) selectNextBranch() tea.Cmd {
 	if !m.gitCtx.Enabled || len(m.gitCtx.Branches) == 0 {
-		return
+		return nil
 	}
 	m.branchIndex = (m.branchIndex + 1) % len(m.gitCtx.Branches)
 	m.gitCtx.Ref2 = m.gitCtx.Branches[m.branchIndex]
-	m.reloadDiff()
+	return m.reloadDiff()
 }
 
-func (m *Model) selectPreviousBranch() {
+func (m *Model) selectPreviousBranch() tea.Cmd {
 	if !m.gitCtx.Enabled || len(m.gitCtx.Branches) == 0 {
-		return
+		return nil
 	}
 	m.branchIndex--
 	if m.branchIndex < 0 {
 		m.branchIndex = len(m.gitCtx.Branches) - 1
 	}
 	m.gitCtx.Ref2 = m.gitCtx.Branches[m.branchIndex]
-	m.reloadDiff()
-}
-
-func (m *Model) reloadDiff() {
-	if m.diffEngine == nil || !m.gitCtx.Enabled {
-		return
-	}
-
-	lines1, err := m.readLinesForRef(m.gitCtx.Ref1)
-	if err != nil {
-		m.err = err
-		return
-	}
-	lines2, err := m.readLinesForRef(m.gitCtx.Ref2)
-	if err != nil {
-		m.err = err
-		return
-	}
-
-	leftLabel := fmt.Sprintf("%s:%s", m.gitCtx.Ref1, m.gitCtx.FilePath)
-	rightLabel := fmt.Sprintf("%s:%s", m.gitCtx.Ref2, m.gitCtx.FilePath)
-
-	m.diffResult = m.diffEngine.DiffLines(lines1, lines2, leftLabel, rightLabel)
-	if m.showBlame {
-		m.gitCtx.Blame, _ = m.collectBlame()
-	}
-
-	m.refreshPaletteEntries()
+	return m.reloadDiff()
 }
 
-func (m *Model) readLinesForRef(ref string) ([]string, error) {
-	if ref == "" || ref == "WORKTREE" {
-		data, err := os.ReadFile(filepath.Join(m.gitCtx.RepoRoot, m.gitCtx.FilePath))
-		if err != nil {
-			return nil, err
-		}
-		text := strings.TrimSuffix(string(data), "\n")
-		if text == "" {
-			return []string{}, nil
-		}
-		return strings.Split(text, "\n"), nil
-	}
-
-	cmd := exec.Command("git", "-C", m.gitCtx.RepoRoot, "show", fmt.Sprintf("%s:%s", ref, m.gitCtx.FilePath))
-	out, err := cmd.Output()
-	if err != nil {
-		return nil, err
+// selectFile moves to another changed file when several files are loaded,
+// such as in review mode.
+func (m *Model) selectFile(delta int) tea.Cmd {
+	if m.rangeDiffMode() {
+		return m.selectRangePair(delta)
 	}
-
-	text := strings.TrimSuffix(string(out), "\n")
-	if text == "" {
-		return []string{}, nil
+	if !m.gitCtx.Enabled || len(m.gitCtx.Files) < 2 {
+		return nil
 	}
 
-	return strings.Split(text, "\n"), nil
+	m.gitCtx.FileIndex = (m.gitCtx.FileIndex + delta + len(m.gitCtx.Files)) % len(m.gitCtx.Files)
+	m.gitCtx.FilePath = m.gitCtx.Files[m.gitCtx.FileIndex]
+	m.scrollToTop()
+	m.statusMessage = fmt.Sprintf("File %d/%d: %s", m.gitCtx.FileIndex+1, len(m.gitCtx.Files), m.gitCtx.FilePath)
+	return m.reloadDiff()
 }
 
-func (m *Model) collectBlame() (map[int]string, error) {
-	blame := make(map[int]string)
-	if !m.gitCtx.Enabled {
-		return blame, nil
-	}
-
-	target := m.gitCtx.FilePath
-	if m.gitCtx.Ref2 != "" && m.gitCtx.Ref2 != "WORKTREE" {
-		target = fmt.Sprintf("%s:%s", m.gitCtx.Ref2, m.gitCtx.FilePath)
-	}
-
-	cmd := exec.Command("git", "-C", m.gitCtx.RepoRoot, "blame", "-l", target)
-	out, err := cmd.Output()
-	if err != nil {
-		return blame, err
-	}
-
-	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
-	for i, line := range lines {
-		blame[i+1] = line
+// sidePath returns the resolved path for one side, falling back to the
+// requested path when the file does not exist there.
+func sidePath(resolved, fallback string) string {
+	if resolved == "" {
+		return fallback
 	}
-
-	return blame, nil
+	return resolved
 }
 
 // updateViewportHeight calculates and sets the viewport height based on screen size and active panels
@@ -2090,7 +2521,7 @@ func (m *Model) updateViewportHeight() {
 	switch m.activePanel {
 	case helpPanel:
 		baseHeight -= m.helpPanelHeight
-	case statsPanel, statusPanel, branchPanel, historyPanel:
+	case statsPanel, statusPanel, branchPanel, historyPanel, lineHistoryPanel:
 		baseHeight -= m.statsPanelHeight
 	}
 
@@ -2102,6 +2533,10 @@ func (m *Model) updateViewportHeight() {
 		baseHeight -= min(8, len(m.settingsEntries)+4)
 	}
 
+	if m.showPicker {
+		baseHeight -= pickerHeight
+	}
+
 	if m.goToLineActive {
 		baseHeight -= 3
 	}
@@ -0,0 +1,363 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/cj3636/gdiff/internal/git"
+)
+
+// RefKind classifies an entry in the ref picker.
+type RefKind int
+
+const (
+	RefBranch RefKind = iota
+	RefRemote
+	RefTag
+	RefCommit
+	RefStash
+	RefWorktree
+	RefExpression
+)
+
+// RefCandidate is a selectable revision in the ref picker.
+type RefCandidate struct {
+	Kind        RefKind
+	Name        string
+	Description string
+}
+
+type pickerSide int
+
+const (
+	pickerLeft pickerSide = iota
+	pickerRight
+)
+
+const (
+	pickerHeight       = 16
+	pickerVisibleItems = 10
+	recentCommitLimit  = 20
+)
+
+func (k RefKind) label() string {
+	switch k {
+	case RefBranch:
+		return "branch"
+	case RefRemote:
+		return "remote"
+	case RefTag:
+		return "tag"
+	case RefCommit:
+		return "commit"
+	case RefStash:
+		return "stash"
+	case RefWorktree:
+		return "worktree"
+	default:
+		return "rev"
+	}
+}
+
+func (s pickerSide) label() string {
+	if s == pickerLeft {
+		return "left"
+	}
+	return "right"
+}
+
+// LoadRefCandidates lists local and remote branches, tags, recent commits,
+// stashes and linked worktrees for the repository.
+func LoadRefCandidates(ctx context.Context, repoRoot string) ([]RefCandidate, error) {
+	refs, err := gitOutputLines(ctx, repoRoot, "for-each-ref",
+		"--format=%(refname)"+fieldSep+"%(refname:short)"+fieldSep+"%(subject)",
+		"refs/heads", "refs/remotes", "refs/tags")
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []RefCandidate
+	for _, line := range refs {
+		fields := strings.SplitN(line, fieldSep, 3)
+		if len(fields) < 3 {
+			continue
+		}
+
+		kind := RefBranch
+		switch {
+		case strings.HasPrefix(fields[0], "refs/remotes/"):
+			if strings.HasSuffix(fields[0], "/HEAD") {
+				continue
+			}
+			kind = RefRemote
+		case strings.HasPrefix(fields[0], "refs/tags/"):
+			kind = RefTag
+		}
+		candidates = append(candidates, RefCandidate{Kind: kind, Name: fields[1], Description: fields[2]})
+	}
+
+	commits, _ := gitOutputLines(ctx, repoRoot, "log", "--format=%H"+fieldSep+"%s", "-n", fmt.Sprint(recentCommitLimit))
+	for _, line := range commits {
+		fields := strings.SplitN(line, fieldSep, 2)
+		if len(fields) < 2 {
+			continue
+		}
+		candidates = append(candidates, RefCandidate{Kind: RefCommit, Name: fields[0], Description: fields[1]})
+	}
+
+	stashes, _ := gitOutputLines(ctx, repoRoot, "stash", "list", "--format=%gd"+fieldSep+"%s")
+	for _, line := range stashes {
+		fields := strings.SplitN(line, fieldSep, 2)
+		if len(fields) < 2 {
+			continue
+		}
+		candidates = append(candidates, RefCandidate{Kind: RefStash, Name: fields[0], Description: fields[1]})
+	}
+
+	worktrees, _ := LoadLinkedWorktrees(ctx, repoRoot)
+	for _, wt := range worktrees {
+		candidates = append(candidates, RefCandidate{Kind: RefWorktree, Name: git.LinkedWorktreePrefix + wt.Path, Description: wt.Branch})
+	}
+
+	return candidates, nil
+}
+
+// ResolveRevision validates a revision expression such as HEAD~3 or
+// main@{yesterday} and returns the commit it names. It asks the repository's
+// cat-file --batch process for expr^{commit} rather than running git
+// rev-parse --verify: both go through git's revision parser and accept the
+// same expressions, and the lookup costs no extra process. Reading names
+// from stdin also means an expression starting with "-" is never an option.
+func ResolveRevision(ctx context.Context, repoRoot, expr string) (string, error) {
+	return git.Open(repoRoot).Resolve(ctx, expr)
+}
+
+// fuzzyScore reports whether every rune of pattern appears in text in order,
+// rewarding consecutive runs and matches at word boundaries.
+func fuzzyScore(pattern, text string) (int, bool) {
+	if pattern == "" {
+		return 0, true
+	}
+
+	p := []rune(strings.ToLower(pattern))
+	t := []rune(strings.ToLower(text))
+
+	score := 0
+	pi := 0
+	prevMatch := -2
+	for ti := 0; ti < len(t) && pi < len(p); ti++ {
+		if t[ti] != p[pi] {
+			continue
+		}
+
+		score++
+		if ti == prevMatch+1 {
+			score += 3
+		}
+		if ti == 0 || (!unicode.IsLetter(t[ti-1]) && !unicode.IsDigit(t[ti-1])) {
+			score += 2
+		}
+		prevMatch = ti
+		pi++
+	}
+
+	if pi < len(p) {
+		return 0, false
+	}
+	return score - len(t)/10, true
+}
+
+// openRefPicker shows the picker straight away and fills it once the refs
+// have been listed in the background.
+func (m *Model) openRefPicker(side pickerSide) tea.Cmd {
+	if !m.gitCtx.Enabled {
+		m.statusMessage = "Git repository not detected - ref picker unavailable"
+		return nil
+	}
+
+	m.showPicker = true
+	m.showCommand = false
+	m.showSettings = false
+	m.goToLineActive = false
+	m.pickerSide = side
+	m.pickerQuery = ""
+	m.pickerError = ""
+	m.pickerIndex = 0
+	m.pickerEntries = nil
+	m.pickerLoading = true
+	m.filterPicker()
+	m.updateViewportHeight()
+
+	repoRoot := m.gitCtx.RepoRoot
+	return m.startJob(func() tea.Msg {
+		candidates, err := LoadRefCandidates(context.Background(), repoRoot)
+		return refsLoadedMsg{candidates: candidates, err: err}
+	})
+}
+
+func (m *Model) applyRefsLoaded(msg refsLoadedMsg) {
+	m.finishJob()
+	m.pickerLoading = false
+	if msg.err != nil {
+		m.pickerError = fmt.Sprintf("Unable to list refs: %v", msg.err)
+		return
+	}
+	m.pickerEntries = msg.candidates
+	m.filterPicker()
+}
+
+func (m *Model) closeRefPicker() {
+	m.showPicker = false
+	m.pickerQuery = ""
+	m.pickerError = ""
+	m.updateViewportHeight()
+}
+
+func (m *Model) filterPicker() {
+	type scored struct {
+		candidate RefCandidate
+		score     int
+	}
+
+	var matches []scored
+	for _, c := range m.pickerEntries {
+		if score, ok := fuzzyScore(m.pickerQuery, displayRef(c.Name)+" "+c.Description); ok {
+			matches = append(matches, scored{candidate: c, score: score})
+		}
+	}
+
+	if m.pickerQuery != "" {
+		sort.SliceStable(matches, func(i, j int) bool { return matches[i].score > matches[j].score })
+	}
+
+	m.pickerMatches = m.pickerMatches[:0]
+	for _, s := range matches {
+		m.pickerMatches = append(m.pickerMatches, s.candidate)
+	}
+
+	if query := strings.TrimSpace(m.pickerQuery); query != "" {
+		m.pickerMatches = append(m.pickerMatches, RefCandidate{Kind: RefExpression, Name: query, Description: "Use revision expression"})
+	}
+
+	if m.pickerIndex >= len(m.pickerMatches) {
+		m.pickerIndex = max(0, len(m.pickerMatches)-1)
+	}
+}
+
+func (m *Model) handlePickerInput(msg tea.KeyMsg) tea.Cmd {
+	switch msg.Type {
+	case tea.KeyEsc:
+		m.closeRefPicker()
+	case tea.KeyTab, tea.KeyShiftTab:
+		if m.pickerSide == pickerLeft {
+			m.pickerSide = pickerRight
+		} else {
+			m.pickerSide = pickerLeft
+		}
+	case tea.KeyUp, tea.KeyCtrlP:
+		if m.pickerIndex > 0 {
+			m.pickerIndex--
+		}
+	case tea.KeyDown, tea.KeyCtrlN:
+		if m.pickerIndex < len(m.pickerMatches)-1 {
+			m.pickerIndex++
+		}
+	case tea.KeyEnter:
+		return m.applyPickerSelection()
+	case tea.KeyBackspace, tea.KeyDelete:
+		if len(m.pickerQuery) > 0 {
+			runes := []rune(m.pickerQuery)
+			m.pickerQuery = string(runes[:len(runes)-1])
+			m.pickerIndex = 0
+			m.pickerError = ""
+			m.filterPicker()
+		}
+	case tea.KeyRunes, tea.KeySpace:
+		m.pickerQuery += string(msg.Runes)
+		m.pickerIndex = 0
+		m.pickerError = ""
+		m.filterPicker()
+	}
+	return nil
+}
+
+func (m *Model) applyPickerSelection() tea.Cmd {
+	if len(m.pickerMatches) == 0 {
+		return nil
+	}
+
+	selected := m.pickerMatches[m.pickerIndex]
+	if selected.Kind == RefExpression {
+		// Validation is a single lookup on the persistent cat-file process, so
+		// it stays synchronous to keep the picker open on a typo.
+		if _, err := ResolveRevision(context.Background(), m.gitCtx.RepoRoot, selected.Name); err != nil {
+			m.pickerError = err.Error()
+			return nil
+		}
+	}
+
+	if m.pickerSide == pickerLeft {
+		m.gitCtx.Ref1 = selected.Name
+	} else {
+		m.gitCtx.Ref2 = selected.Name
+		for i, b := range m.gitCtx.Branches {
+			if b == selected.Name {
+				m.branchIndex = i
+				break
+			}
+		}
+	}
+
+	m.activeCommit = ""
+	m.closeRefPicker()
+	m.statusMessage = fmt.Sprintf("Set %s ref to %s", m.pickerSide.label(), displayRef(selected.Name))
+	return m.reloadDiff()
+}
+
+func (m Model) renderRefPicker() string {
+	lines := []string{
+		fmt.Sprintf(" Select %s ref  (tab: switch side, enter: apply, esc: close)", m.pickerSide.label()),
+		"> " + m.pickerQuery + "▏",
+	}
+	if m.pickerError != "" {
+		lines = append(lines, m.styles.removed.Render(m.pickerError))
+	} else {
+		lines = append(lines, "")
+	}
+
+	start := 0
+	if m.pickerIndex >= pickerVisibleItems {
+		start = m.pickerIndex - pickerVisibleItems + 1
+	}
+	end := min(start+pickerVisibleItems, len(m.pickerMatches))
+
+	for i := start; i < end; i++ {
+		c := m.pickerMatches[i]
+		label := fmt.Sprintf("%-7s %-30s %s", c.Kind.label(), truncate(displayRef(c.Name), 30), truncate(c.Description, 50))
+		if i == m.pickerIndex {
+			label = m.styles.selection.Render("> " + label)
+		} else {
+			label = "  " + label
+		}
+		lines = append(lines, label)
+	}
+
+	switch {
+	case m.pickerLoading:
+		lines = append(lines, "  Loading refs...")
+	case len(m.pickerMatches) == 0:
+		lines = append(lines, "  No refs found")
+	}
+
+	return m.styles.help.Copy().
+		BorderStyle(lipgloss.RoundedBorder()).
+		BorderForeground(m.config.Theme.BorderFg).
+		Padding(0, 1).
+		Width(m.width - 2).
+		Render(strings.Join(lines, "\n"))
+}
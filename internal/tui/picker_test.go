@@ -0,0 +1,52 @@
+package tui
+
+import "testing"
+
+func TestFuzzyScoreMatches(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		text    string
+		match   bool
+	}{
+		{name: "empty pattern", pattern: "", text: "main", match: true},
+		{name: "exact", pattern: "main", text: "main", match: true},
+		{name: "subsequence", pattern: "fbr", text: "feature/bar", match: true},
+		{name: "case insensitive", pattern: "HEAD", text: "head~2", match: true},
+		{name: "out of order", pattern: "niam", text: "main", match: false},
+		{name: "missing rune", pattern: "mainx", text: "main", match: false},
+		{name: "longer than text", pattern: "mainline", text: "main", match: false},
+		{name: "non-ascii", pattern: "ü", text: "grün", match: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := fuzzyScore(tt.pattern, tt.text); ok != tt.match {
+				t.Errorf("fuzzyScore(%q, %q) matched = %v, want %v", tt.pattern, tt.text, ok, tt.match)
+			}
+		})
+	}
+}
+
+func TestFuzzyScoreRanking(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		better  string
+		worse   string
+	}{
+		{name: "consecutive run beats scattered", pattern: "fix", better: "fix/login", worse: "f-i-x"},
+		{name: "word start beats mid-word", pattern: "bar", better: "feature/bar", worse: "foobarbaz"},
+		{name: "shorter text wins a tie", pattern: "main", better: "main", worse: "main-with-a-much-longer-name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			better, _ := fuzzyScore(tt.pattern, tt.better)
+			worse, _ := fuzzyScore(tt.pattern, tt.worse)
+			if better <= worse {
+				t.Errorf("fuzzyScore(%q): %q scored %d, not above %q at %d", tt.pattern, tt.better, better, tt.worse, worse)
+			}
+		})
+	}
+}
@@ -0,0 +1,301 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/cj3636/gdiff/internal/diff"
+	"github.com/cj3636/gdiff/internal/git"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// rangeDiffMinSimilarity is the lowest patch similarity at which two commits
+// are considered versions of each other.
+const rangeDiffMinSimilarity = 0.5
+
+var hunkHeaderPattern = regexp.MustCompile(`^@@ -\d+(,\d+)? \+\d+(,\d+)? @@ ?`)
+
+// RangePair is one entry of a range-diff: a commit from the old range matched
+// with its counterpart in the new range. Old or New is nil when the commit was
+// dropped or added by the rewrite.
+type RangePair struct {
+	Old, New  *Commit
+	OldIndex  int
+	NewIndex  int
+	OldPatch  []string
+	NewPatch  []string
+	identical bool
+}
+
+// Marker summarises the pair the way git range-diff does: = unchanged,
+// ! modified, < dropped and > added.
+func (p RangePair) Marker() string {
+	switch {
+	case p.New == nil:
+		return "<"
+	case p.Old == nil:
+		return ">"
+	case p.identical:
+		return "="
+	default:
+		return "!"
+	}
+}
+
+// OldLabel names the old side of the pair for diff headers.
+func (p RangePair) OldLabel() string {
+	if p.Old == nil {
+		return "-: -------"
+	}
+	return fmt.Sprintf("%d: %s %s", p.OldIndex+1, p.Old.ShortHash, p.Old.Subject)
+}
+
+// NewLabel names the new side of the pair for diff headers.
+func (p RangePair) NewLabel() string {
+	if p.New == nil {
+		return "-: -------"
+	}
+	return fmt.Sprintf("%d: %s %s", p.NewIndex+1, p.New.ShortHash, p.New.Subject)
+}
+
+// LoadRangeDiff lists the commits of both ranges, pairs them by patch
+// similarity and returns the pairs in the order of the new range, with
+// dropped commits placed next to their old neighbours.
+func LoadRangeDiff(ctx context.Context, repoRoot, oldRange, newRange string) ([]RangePair, error) {
+	oldCommits, oldPatches, err := loadRangePatches(ctx, repoRoot, oldRange)
+	if err != nil {
+		return nil, err
+	}
+	newCommits, newPatches, err := loadRangePatches(ctx, repoRoot, newRange)
+	if err != nil {
+		return nil, err
+	}
+	return pairRangeCommits(oldCommits, oldPatches, newCommits, newPatches), nil
+}
+
+// pairRangeCommits matches each commit of the old range with the new commit
+// whose patch is most alike, best matches first, and orders the pairs as
+// LoadRangeDiff returns them.
+func pairRangeCommits(oldCommits []Commit, oldPatches [][]string, newCommits []Commit, newPatches [][]string) []RangePair {
+	oldChanged := make([][]string, len(oldPatches))
+	for i, patch := range oldPatches {
+		oldChanged[i] = changedLines(patch)
+	}
+	newChanged := make([][]string, len(newPatches))
+	for i, patch := range newPatches {
+		newChanged[i] = changedLines(patch)
+	}
+
+	type candidate struct {
+		old, new int
+		score    float64
+	}
+	var candidates []candidate
+	for i := range oldChanged {
+		for j := range newChanged {
+			if score := patchSimilarity(oldChanged[i], newChanged[j]); score >= rangeDiffMinSimilarity {
+				candidates = append(candidates, candidate{old: i, new: j, score: score})
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(a, b int) bool { return candidates[a].score > candidates[b].score })
+
+	matchOld := make([]int, len(oldCommits))
+	matchNew := make([]int, len(newCommits))
+	for i := range matchOld {
+		matchOld[i] = -1
+	}
+	for i := range matchNew {
+		matchNew[i] = -1
+	}
+	for _, c := range candidates {
+		if matchOld[c.old] == -1 && matchNew[c.new] == -1 {
+			matchOld[c.old] = c.new
+			matchNew[c.new] = c.old
+		}
+	}
+
+	var pairs []RangePair
+	nextOld := 0
+	emitDropped := func(limit int) {
+		for ; nextOld < limit; nextOld++ {
+			if matchOld[nextOld] == -1 {
+				pairs = append(pairs, RangePair{Old: &oldCommits[nextOld], OldIndex: nextOld, NewIndex: -1, OldPatch: oldPatches[nextOld]})
+			}
+		}
+	}
+
+	for j := range newCommits {
+		pair := RangePair{New: &newCommits[j], NewIndex: j, OldIndex: -1, NewPatch: newPatches[j]}
+		if i := matchNew[j]; i != -1 {
+			emitDropped(i)
+			pair.Old = &oldCommits[i]
+			pair.OldIndex = i
+			pair.OldPatch = oldPatches[i]
+			pair.identical = strings.Join(oldPatches[i], "\n") == strings.Join(newPatches[j], "\n")
+		}
+		pairs = append(pairs, pair)
+	}
+	emitDropped(len(oldCommits))
+
+	return pairs
+}
+
+// loadRangePatches returns the non-merge commits of rng oldest first together
+// with their normalised patch text, read with a single git log -p.
+func loadRangePatches(ctx context.Context, repoRoot, rng string) ([]Commit, [][]string, error) {
+	format := recordSep + strings.Join([]string{"%H", "%h", "%P", "%an", "%ad", "%s", "%b"}, fieldSep) + fieldSep
+	out, err := git.Open(repoRoot).Run(ctx, "log", "--reverse", "--no-merges", "-p", "--no-color", "--no-ext-diff",
+		"--format="+format, "--date=short", rng)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to list %s: %w", rng, err)
+	}
+
+	var commits []Commit
+	var patches [][]string
+	for _, record := range strings.Split(string(out), recordSep) {
+		fields := strings.SplitN(record, fieldSep, 8)
+		if len(fields) < 8 {
+			continue
+		}
+
+		c := Commit{
+			Hash:      fields[0],
+			ShortHash: fields[1],
+			Parents:   strings.Fields(fields[2]),
+			Author:    fields[3],
+			Date:      fields[4],
+			Subject:   fields[5],
+			Body:      strings.TrimSpace(fields[6]),
+		}
+		commits = append(commits, c)
+		patches = append(patches, normalizePatch(c, fields[7]))
+	}
+	return commits, patches, nil
+}
+
+// normalizePatch rewrites a commit into the form git range-diff compares: the
+// message under a "## Commit message ##" header, one "## path ##" header per
+// file and hunk headers without line numbers, which shift after a rebase.
+func normalizePatch(c Commit, raw string) []string {
+	lines := []string{"## Commit message ##", "    " + c.Subject}
+	if c.Body != "" {
+		lines = append(lines, "    ")
+		for _, line := range strings.Split(c.Body, "\n") {
+			lines = append(lines, "    "+line)
+		}
+	}
+
+	for _, line := range strings.Split(strings.TrimRight(raw, "\n"), "\n") {
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "diff --git "):
+			path := line[len("diff --git "):]
+			if _, after, ok := strings.Cut(path, " b/"); ok {
+				path = after
+			}
+			lines = append(lines, "", "## "+path+" ##")
+		case strings.HasPrefix(line, "index "),
+			strings.HasPrefix(line, "--- "),
+			strings.HasPrefix(line, "+++ "):
+			continue
+		case strings.HasPrefix(line, "@@"):
+			lines = append(lines, strings.TrimRight("@@ "+hunkHeaderPattern.ReplaceAllString(line, ""), " "))
+		default:
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func changedLines(patch []string) []string {
+	var changed []string
+	for _, line := range patch {
+		if strings.HasPrefix(line, "+") || strings.HasPrefix(line, "-") {
+			changed = append(changed, line)
+		}
+	}
+	return changed
+}
+
+func patchSimilarity(a, b []string) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	matcher := difflib.NewMatcher(a, b)
+	if matcher.QuickRatio() < rangeDiffMinSimilarity {
+		return 0
+	}
+	return matcher.Ratio()
+}
+
+func (m Model) rangeDiffMode() bool {
+	return len(m.gitCtx.RangePairs) > 0
+}
+
+func (m *Model) selectRangePair(delta int) tea.Cmd {
+	count := len(m.gitCtx.RangePairs)
+	m.gitCtx.PairIndex = (m.gitCtx.PairIndex + delta + count) % count
+	m.scrollToTop()
+	pair := m.gitCtx.RangePairs[m.gitCtx.PairIndex]
+	m.statusMessage = fmt.Sprintf("Pair %d/%d: %s %s %s", m.gitCtx.PairIndex+1, count, pair.OldLabel(), pair.Marker(), pair.NewLabel())
+	return m.reloadDiff()
+}
+
+// nestedMarker splits the inner patch marker off a range-diff line so it can
+// be styled apart from the outer +/- of the diff-of-diffs.
+func (m Model) nestedMarker(line diff.DiffLine) (string, string, bool) {
+	if !m.rangeDiffMode() || line.Content == "" {
+		return "", "", false
+	}
+
+	marker := line.Content[:1]
+	var style lipgloss.Style
+	switch marker {
+	case "+":
+		style = lipgloss.NewStyle().Foreground(m.config.Theme.AddedFg).Bold(true).Reverse(true)
+	case "-":
+		style = lipgloss.NewStyle().Foreground(m.config.Theme.RemovedFg).Bold(true).Reverse(true)
+	case "@", "#":
+		style = lipgloss.NewStyle().Foreground(m.config.Theme.LineNumberFg).Bold(true)
+	default:
+		style = m.styles.unchanged
+	}
+	return style.Render(marker), line.Content[1:], true
+}
+
+func (m Model) renderRangeDiffPanel() string {
+	const visible = 12
+
+	lines := []string{
+		fmt.Sprintf("Range-diff: %s → %s  (%d pairs, %s/%s to switch)", displayRef(m.gitCtx.Ref1), displayRef(m.gitCtx.Ref2), len(m.gitCtx.RangePairs),
+			m.keyDisplay(actionPrevFile), m.keyDisplay(actionNextFile)),
+		"─────────",
+	}
+
+	start := max(0, m.gitCtx.PairIndex-visible/2)
+	end := min(start+visible, len(m.gitCtx.RangePairs))
+	for i := start; i < end; i++ {
+		pair := m.gitCtx.RangePairs[i]
+		label := fmt.Sprintf("%-30s %s %s", truncate(pair.OldLabel(), 30), pair.Marker(), truncate(pair.NewLabel(), 50))
+		if i == m.gitCtx.PairIndex {
+			label = m.styles.selection.Render("> " + label)
+		} else {
+			label = "  " + label
+		}
+		lines = append(lines, label)
+	}
+
+	return m.styles.help.Copy().
+		BorderStyle(lipgloss.RoundedBorder()).
+		BorderForeground(m.config.Theme.BorderFg).
+		Padding(0, 1).
+		Width(m.width - 2).
+		Render(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,163 @@
+package tui
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestNormalizePatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		commit   Commit
+		raw      string
+		expected []string
+	}{
+		{
+			name:     "subject only",
+			commit:   Commit{Subject: "Fix typo"},
+			expected: []string{"## Commit message ##", "    Fix typo"},
+		},
+		{
+			name:   "body is indented under the subject",
+			commit: Commit{Subject: "Add parser", Body: "First line.\nSecond line."},
+			expected: []string{
+				"## Commit message ##", "    Add parser", "    ",
+				"    First line.", "    Second line.",
+			},
+		},
+		{
+			name:   "file headers and hunk line numbers are dropped",
+			commit: Commit{Subject: "Change f"},
+			raw: strings.Join([]string{
+				"",
+				"diff --git a/f.go b/f.go",
+				"index 1234567..89abcde 100644",
+				"--- a/f.go",
+				"+++ b/f.go",
+				"@@ -10,3 +12,4 @@ func main() {",
+				" 	a()",
+				"-	b()",
+				"+	c()",
+				"@@ -40 +43 @@",
+				"+d",
+				"",
+			}, "\n"),
+			expected: []string{
+				"## Commit message ##", "    Change f",
+				"", "## f.go ##",
+				"@@ func main() {", " 	a()", "-	b()", "+	c()",
+				"@@", "+d",
+			},
+		},
+		{
+			name:   "renamed file is named by its new path",
+			commit: Commit{Subject: "Move"},
+			raw:    "diff --git a/old.go b/new.go\nsimilarity index 100%\nrename from old.go\nrename to new.go\n",
+			expected: []string{
+				"## Commit message ##", "    Move",
+				"", "## new.go ##",
+				"similarity index 100%", "rename from old.go", "rename to new.go",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizePatch(tt.commit, tt.raw); !slices.Equal(got, tt.expected) {
+				t.Errorf("normalizePatch() =\n%q\nwant\n%q", got, tt.expected)
+			}
+		})
+	}
+}
+
+// rangeCommit builds a commit whose patch adds the given lines.
+func rangeCommit(subject string, added ...string) (Commit, []string) {
+	c := Commit{Hash: subject, ShortHash: subject, Subject: subject}
+	patch := []string{"## Commit message ##", "    " + subject, "", "## f ##", "@@"}
+	for _, line := range added {
+		patch = append(patch, "+"+line)
+	}
+	return c, patch
+}
+
+func TestPairRangeCommits(t *testing.T) {
+	type side struct {
+		subject string
+		added   []string
+	}
+	a := side{"A", []string{"a1", "a2", "a3", "a4"}}
+	b := side{"B", []string{"b1", "b2", "b3", "b4"}}
+	bEdited := side{"B", []string{"b1", "b2", "b3", "b5"}}
+	c := side{"C", []string{"c1", "c2", "c3", "c4"}}
+	d := side{"D", []string{"d1", "d2", "d3", "d4"}}
+
+	tests := []struct {
+		name     string
+		old, new []side
+		expected []string // marker and subjects of each pair
+	}{
+		{
+			name:     "unchanged range",
+			old:      []side{a, b},
+			new:      []side{a, b},
+			expected: []string{"= A A", "= B B"},
+		},
+		{
+			name:     "edited commit",
+			old:      []side{a, b},
+			new:      []side{a, bEdited},
+			expected: []string{"= A A", "! B B"},
+		},
+		{
+			name:     "dropped commit keeps its place",
+			old:      []side{a, b, c},
+			new:      []side{a, c},
+			expected: []string{"= A A", "< B -", "= C C"},
+		},
+		{
+			name:     "reordered, added and dropped",
+			old:      []side{a, b, c},
+			new:      []side{bEdited, d, a},
+			expected: []string{"! B B", "> - D", "= A A", "< C -"},
+		},
+		{
+			name:     "nothing in common",
+			old:      []side{a},
+			new:      []side{d},
+			expected: []string{"> - D", "< A -"},
+		},
+	}
+
+	build := func(sides []side) ([]Commit, [][]string) {
+		var commits []Commit
+		var patches [][]string
+		for _, s := range sides {
+			commit, patch := rangeCommit(s.subject, s.added...)
+			commits = append(commits, commit)
+			patches = append(patches, patch)
+		}
+		return commits, patches
+	}
+	subject := func(c *Commit) string {
+		if c == nil {
+			return "-"
+		}
+		return c.Subject
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oldCommits, oldPatches := build(tt.old)
+			newCommits, newPatches := build(tt.new)
+
+			var got []string
+			for _, pair := range pairRangeCommits(oldCommits, oldPatches, newCommits, newPatches) {
+				got = append(got, pair.Marker()+" "+subject(pair.Old)+" "+subject(pair.New))
+			}
+			if !slices.Equal(got, tt.expected) {
+				t.Errorf("pairRangeCommits() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
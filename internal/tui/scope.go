@@ -0,0 +1,78 @@
+package tui
+
+import (
+	"errors"
+
+	"github.com/cj3636/gdiff/internal/export"
+)
+
+// exportScope is the part of the diff that a copy or save covers.
+type exportScope int
+
+const (
+	scopeDiff exportScope = iota
+	scopeSelection
+	scopeHunk
+)
+
+func (s exportScope) noun() string {
+	switch s {
+	case scopeSelection:
+		return "selection"
+	case scopeHunk:
+		return "hunk"
+	default:
+		return "diff"
+	}
+}
+
+// toggleSelection starts a visual selection at the cursor, or drops the one
+// in progress. The selection runs from there to wherever the cursor moves.
+func (m *Model) toggleSelection() {
+	if m.viewport.selecting {
+		m.viewport.selecting = false
+		m.statusMessage = "Selection cleared"
+		return
+	}
+	m.viewport.selecting = true
+	m.viewport.anchor = m.viewport.cursor
+	m.statusMessage = "Selecting lines: move to extend, y to copy, esc to cancel"
+}
+
+// selectionRange returns the selected lines, end exclusive.
+func (m Model) selectionRange() (int, int) {
+	return min(m.viewport.anchor, m.viewport.cursor), max(m.viewport.anchor, m.viewport.cursor) + 1
+}
+
+func (m Model) inSelection(index int) bool {
+	if !m.viewport.selecting {
+		return false
+	}
+	start, end := m.selectionRange()
+	return index >= start && index < end
+}
+
+// scopeOptions returns the export options limiting an export to scope, and
+// the span of lines it covers, end exclusive.
+func (m Model) scopeOptions(scope exportScope) (export.Options, int, int, error) {
+	var opts export.Options
+	switch scope {
+	case scopeSelection:
+		if !m.viewport.selecting {
+			return opts, 0, 0, errors.New("no lines are selected; press " + m.keyDisplay(actionVisualSelect) + " to start a selection")
+		}
+		start, end := m.selectionRange()
+		opts.Lines = []export.LineRange{{Start: start, End: end}}
+		return opts, start, end, nil
+	case scopeHunk:
+		for i, h := range m.diffResult.Hunks(m.config.ContextLines) {
+			if m.viewport.cursor >= h.Start && m.viewport.cursor < h.End {
+				opts.Hunks = []int{i}
+				return opts, h.Start, h.End, nil
+			}
+		}
+		return opts, 0, 0, errors.New("the cursor is not on a hunk")
+	default:
+		return opts, m.viewport.offset, m.viewport.offset + m.viewport.height, nil
+	}
+}
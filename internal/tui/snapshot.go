@@ -0,0 +1,55 @@
+package tui
+
+import (
+	"github.com/charmbracelet/lipgloss"
+	"github.com/cj3636/gdiff/internal/config"
+	"github.com/cj3636/gdiff/internal/diff"
+	"github.com/muesli/termenv"
+)
+
+// SnapshotOptions selects what RenderSnapshot draws.
+type SnapshotOptions struct {
+	Start      int // first diff line, 0-based
+	End        int // one past the last line; 0 means the end of the diff
+	Width      int
+	SideBySide bool
+}
+
+// RenderSnapshot draws part of a diff the way the viewer would at the given
+// width, without a terminal.
+func RenderSnapshot(result *diff.DiffResult, cfg *config.Config, engine *diff.Engine, gitCtx GitContext, opts SnapshotOptions) []string {
+	m := NewModel(result, cfg, engine, gitCtx)
+	m.loading = false
+	m.renderedLines = result.Lines
+	m.width = opts.Width
+	m.sideBySideMode = opts.SideBySide
+	m.viewport.cursor = -1
+
+	end := opts.End
+	if end <= 0 || end > len(result.Lines) {
+		end = len(result.Lines)
+	}
+	return m.snapshot(opts.Start, end)
+}
+
+// snapshot renders the title bar and diff lines [start, end) at the full
+// width, leaving out the minimap. Colours are rendered as truecolor whatever
+// the terminal supports, so the rows can be turned into an image.
+func (m Model) snapshot(start, end int) []string {
+	profile := lipgloss.ColorProfile()
+	lipgloss.SetColorProfile(termenv.TrueColor)
+	defer lipgloss.SetColorProfile(profile)
+
+	start = max(0, start)
+	end = min(end, len(m.diffResult.Lines))
+	rows := []string{m.renderTitle()}
+	if start >= end {
+		return rows
+	}
+
+	width := max(20, m.width)
+	if m.sideBySideMode {
+		return append(rows, m.renderSideBySideLines(start, end, width, m.diffResult.Lines)...)
+	}
+	return append(rows, m.renderUnifiedLines(start, end, width, m.diffResult.Lines)...)
+}
@@ -0,0 +1,248 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/cj3636/gdiff/internal/git"
+)
+
+// submoduleLogLimit caps the commits listed for a submodule pointer change.
+const submoduleLogLimit = 50
+
+// emptyTree is git's well-known empty tree, used as the missing side when a
+// submodule was added or removed.
+const emptyTree = "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+
+var errNotSubmodule = errors.New("not a submodule")
+
+// SubmoduleChange describes a submodule pointer moving between two refs.
+// Old or New is empty when the submodule is absent on that side.
+type SubmoduleChange struct {
+	Path    string
+	Old     string
+	New     string
+	Added   []Commit // reachable from New but not Old
+	Removed []Commit // reachable from Old but not New, after a rewind
+	LogErr  error
+}
+
+// LoadSubmoduleChange reads the commits the superproject records for the
+// submodule at relPath on each side and, when the submodule is checked out,
+// the log between them.
+func LoadSubmoduleChange(ctx context.Context, repoRoot, relPath, leftRef, rightRef string) (SubmoduleChange, error) {
+	repo := git.Open(repoRoot)
+	change := SubmoduleChange{Path: relPath}
+	oldOK, newOK := false, false
+	change.Old, oldOK = repo.Gitlink(ctx, leftRef, relPath)
+	change.New, newOK = repo.Gitlink(ctx, rightRef, relPath)
+	if !oldOK && !newOK {
+		return change, fmt.Errorf("%s: %w", relPath, errNotSubmodule)
+	}
+	if change.Old == "" || change.New == "" || change.Old == change.New {
+		return change, nil
+	}
+
+	subRoot := filepath.Join(repoRoot, relPath)
+	if top, err := git.TopLevel(subRoot); err != nil || top != subRoot {
+		change.LogErr = fmt.Errorf("submodule not checked out")
+		return change, nil
+	}
+	change.Added, change.LogErr = LoadFileHistory(ctx, subRoot, "", change.Old+".."+change.New, 0, submoduleLogLimit)
+	if change.LogErr == nil {
+		change.Removed, change.LogErr = LoadFileHistory(ctx, subRoot, "", change.New+".."+change.Old, 0, submoduleLogLimit)
+	}
+	return change, nil
+}
+
+// Lines renders each side the way git diff --submodule=log does: the
+// recorded commit followed by the commits only that side contains.
+func (c SubmoduleChange) Lines() ([]string, []string) {
+	side := func(commit, mark string, log []Commit) []string {
+		if commit == "" {
+			return []string{}
+		}
+		lines := []string{"Subproject commit " + commit}
+		for _, entry := range log {
+			lines = append(lines, fmt.Sprintf("  %s %s %s", mark, entry.ShortHash, entry.Subject))
+		}
+		return lines
+	}
+
+	left := side(c.Old, "<", c.Removed)
+	right := side(c.New, ">", c.Added)
+	if c.LogErr != nil {
+		right = append(right, fmt.Sprintf("  (commit log unavailable: %v)", c.LogErr))
+	}
+	return left, right
+}
+
+// Summary describes the change in a few words for status listings.
+func (c SubmoduleChange) Summary() string {
+	switch {
+	case c.Old == "":
+		return "submodule added at " + shortHash(c.New)
+	case c.New == "":
+		return "submodule removed"
+	case c.Old == c.New:
+		return "submodule has local changes"
+	case c.LogErr != nil:
+		return fmt.Sprintf("submodule %s..%s", shortHash(c.Old), shortHash(c.New))
+	case len(c.Removed) > 0:
+		return fmt.Sprintf("submodule %s..%s, %d new, %d rewound", shortHash(c.Old), shortHash(c.New), len(c.Added), len(c.Removed))
+	default:
+		return fmt.Sprintf("submodule %s..%s, %d new", shortHash(c.Old), shortHash(c.New), len(c.Added))
+	}
+}
+
+func shortHash(oid string) string {
+	return oid[:min(len(oid), 7)]
+}
+
+// LoadStatus returns git status --short with submodule entries annotated by
+// how their pointer moved.
+func LoadStatus(ctx context.Context, repoRoot string) ([]string, error) {
+	// Not Lines: trimming would eat the leading space of the first entry.
+	out, err := git.Open(repoRoot).Run(ctx, "status", "--short")
+	if err != nil {
+		return nil, err
+	}
+	lines := git.SplitLines(out)
+
+	for i, line := range lines {
+		if len(line) < 4 {
+			continue
+		}
+		path := line[3:]
+		if info, err := os.Stat(filepath.Join(repoRoot, path)); err != nil || !info.IsDir() {
+			continue
+		}
+		if change, err := LoadSubmoduleChange(ctx, repoRoot, path, "HEAD", git.Worktree); err == nil {
+			lines[i] = fmt.Sprintf("%s  (%s)", line, change.Summary())
+		}
+	}
+	return lines, nil
+}
+
+// SubmoduleContext descends into the submodule at g.FilePath, listing the
+// files that changed between the two recorded commits so they can be
+// stepped through like a review.
+func SubmoduleContext(ctx context.Context, g GitContext) (GitContext, error) {
+	change, err := LoadSubmoduleChange(ctx, g.RepoRoot, g.FilePath, g.Ref1, g.Ref2)
+	if err != nil {
+		return GitContext{}, err
+	}
+
+	subRoot := filepath.Join(g.RepoRoot, g.FilePath)
+	if top, err := git.TopLevel(subRoot); err != nil || top != subRoot {
+		return GitContext{}, fmt.Errorf("submodule %s is not checked out", g.FilePath)
+	}
+
+	sub := GitContext{
+		Enabled:   true,
+		RepoRoot:  subRoot,
+		Ref1:      submoduleRef(g.Ref1, change.Old, g.FilePath),
+		Ref2:      submoduleRef(g.Ref2, change.New, g.FilePath),
+		Submodule: filepath.Join(g.Submodule, g.FilePath),
+	}
+
+	// A working tree side is diffed from inside that working tree, with no
+	// revision for it, as ResolvePaths does.
+	root, args := subRoot, []string{"diff", "--name-only", "-z", "-M"}
+	switch {
+	case git.OnDisk(sub.Ref1) && git.OnDisk(sub.Ref2):
+		return GitContext{}, fmt.Errorf("submodule %s is read from a working tree on both sides", g.FilePath)
+	case git.OnDisk(sub.Ref1):
+		root, _ = gitTarget(subRoot, sub.Ref1)
+		args = append(args, "-R", sub.Ref2)
+	case git.OnDisk(sub.Ref2):
+		root, _ = gitTarget(subRoot, sub.Ref2)
+		args = append(args, sub.Ref1)
+	default:
+		args = append(args, sub.Ref1, sub.Ref2)
+	}
+	out, err := git.Open(root).Run(ctx, args...)
+	if err != nil {
+		return GitContext{}, err
+	}
+	for _, name := range strings.Split(string(out), "\x00") {
+		if name != "" {
+			sub.Files = append(sub.Files, name)
+		}
+	}
+	if len(sub.Files) == 0 {
+		return GitContext{}, fmt.Errorf("no file changes in submodule %s", g.FilePath)
+	}
+
+	sub.FilePath = sub.Files[0]
+	sub.HistoryRef = change.New
+	if change.Old != "" {
+		sub.HistoryRef = change.Old + ".." + sub.HistoryRef
+	}
+	sub.Status, _ = LoadStatus(ctx, subRoot)
+	sub.CommitHistory, _ = LoadFileHistory(ctx, subRoot, "", sub.HistoryRef, 0, HistoryPageSize)
+	return sub, nil
+}
+
+// submoduleRef picks the revision to read inside the submodule for one side
+// of the superproject diff. Working tree sides stay on disk so uncommitted
+// submodule edits show up.
+func submoduleRef(ref, commit, relPath string) string {
+	if dir, ok := git.LinkedWorktree(ref); ok {
+		return git.LinkedWorktreePrefix + filepath.Join(dir, relPath)
+	}
+	if git.IsWorktree(ref) {
+		return git.Worktree
+	}
+	if commit == "" {
+		return emptyTree
+	}
+	return commit
+}
+
+type submoduleLoadedMsg struct {
+	ctx GitContext
+	err error
+}
+
+// toggleSubmodule enters the submodule under the current file, or returns
+// to the parent repository when the current file is not a submodule.
+func (m *Model) toggleSubmodule() tea.Cmd {
+	if !m.gitCtx.Enabled || m.rangeDiffMode() {
+		return nil
+	}
+
+	g := m.gitCtx
+	return m.startJob(func() tea.Msg {
+		sub, err := SubmoduleContext(context.Background(), g)
+		return submoduleLoadedMsg{ctx: sub, err: err}
+	})
+}
+
+func (m *Model) applySubmoduleLoaded(msg submoduleLoadedMsg) tea.Cmd {
+	m.finishJob()
+	if msg.err != nil {
+		if errors.Is(msg.err, errNotSubmodule) && len(m.submoduleParents) > 0 {
+			last := len(m.submoduleParents) - 1
+			m.gitCtx = m.submoduleParents[last]
+			m.submoduleParents = m.submoduleParents[:last]
+			m.statusMessage = "Back in " + filepath.Base(m.gitCtx.RepoRoot)
+			m.scrollToTop()
+			return m.reloadDiff()
+		}
+		m.statusMessage = fmt.Sprintf("Unable to open submodule: %v", msg.err)
+		return nil
+	}
+
+	m.submoduleParents = append(m.submoduleParents, m.gitCtx)
+	m.gitCtx = msg.ctx
+	m.gitCtx.ShowBlame = m.showBlame
+	m.scrollToTop()
+	m.statusMessage = fmt.Sprintf("Submodule %s: %d files changed", m.gitCtx.Submodule, len(m.gitCtx.Files))
+	return m.reloadDiff()
+}
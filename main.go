@@ -1,16 +1,24 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/cj3636/gdiff/internal/config"
 	"github.com/cj3636/gdiff/internal/diff"
 	"github.com/cj3636/gdiff/internal/export"
+	"github.com/cj3636/gdiff/internal/git"
 	"github.com/cj3636/gdiff/internal/tui"
 	flag "github.com/spf13/pflag"
 )
@@ -23,13 +31,46 @@ var (
 	language         string
 	tokenPatterns    map[string]string
 	tabSize          int
+	contextLines     int
+	textWidth        int
 	help             bool
 	ref1             string
 	ref2             string
+	worktreePath     string
 	showBlame        bool
+	review           bool
+	rangeDiff        bool
+	blameHeatmap     bool
 	exportFormat     string
 	exportFile       string
 	exportCopy       bool
+	exportLayout     string
+	exportColors     string
+	loadPath         string
+	templateName     string
+	templateDir      string
+	exportLines      string
+	exportHunks      []int
+	exportSite       string
+	noRedact         bool
+	redactPatterns   []string
+	brief            bool
+	showStat         bool
+	showNumstat      bool
+	showShortstat    bool
+	maxAdded         int
+	maxRemoved       int
+	maxChangedPct    float64
+	reportIdentical  bool
+)
+
+// Exit statuses follow diff(1) so scripts can branch on the result.
+const (
+	exitSame    = 0
+	exitDiffer  = 1
+	exitTrouble = 2
+	// exitLimit reports a file over a --max-* limit, apart from trouble.
+	exitLimit = 3
 )
 
 func init() {
@@ -40,12 +81,36 @@ func init() {
 	flag.StringVar(&language, "language", "", "Language or file extension hint for tokenization")
 	flag.StringToStringVar(&tokenPatterns, "tokenizer", map[string]string{}, "Override token regex per extension (e.g. .txt=\\w+)")
 	flag.IntVarP(&tabSize, "tab-size", "t", 4, "Set tab size")
+	flag.IntVarP(&contextLines, "unified", "U", 3, "Lines of context around each hunk in patch and context exports")
+	flag.IntVarP(&textWidth, "width", "W", export.DefaultWidth, "Total width of side-by-side text exports")
 	flag.StringVar(&ref1, "ref1", "", "Git reference for the left side (defaults to HEAD if ref2 is set)")
 	flag.StringVar(&ref2, "ref2", "", "Git reference for the right side (defaults to working tree)")
+	flag.StringVar(&worktreePath, "worktree", "", "Read the left side from the same path in another linked worktree")
+	flag.BoolVar(&review, "review", false, "Review every file changed since the merge-base with a target branch (defaults to upstream, then main)")
+	flag.BoolVar(&rangeDiff, "range-diff", false, "Compare two versions of a patch series commit by commit (like git range-diff)")
 	flag.BoolVar(&showBlame, "blame", false, "Show git blame information when available")
-	flag.StringVar(&exportFormat, "export-format", "", "Export diff as html, markdown, or ansi without launching the TUI")
+	flag.BoolVar(&blameHeatmap, "blame-heatmap", false, "Colour the blame column by commit age")
+	flag.StringVar(&exportFormat, "export-format", "", "Export diff as html, markdown, markdown-html, ansi, json, ndjson, template, svg, patch, normal, context, ed, or side-by-side without launching the TUI")
 	flag.StringVar(&exportFile, "export-file", "", "Write exported diff to the provided file path")
 	flag.BoolVar(&exportCopy, "export-copy", false, "Copy the exported diff to your clipboard")
+	flag.StringVar(&exportLayout, "export-layout", "unified", "Layout of HTML exports: unified or side-by-side")
+	flag.StringVar(&exportColors, "export-colors", "auto", "Colour depth of ANSI exports: auto, 16, 256, or truecolor")
+	flag.StringVar(&templateName, "template", "", "Export through a template, by name from --template-dir or by path")
+	flag.StringVar(&templateDir, "template-dir", config.DefaultTemplateDir(), "Directory holding export templates (name.tmpl, or name.html.tmpl for HTML)")
+	flag.StringVar(&exportLines, "export-lines", "", "Export only these diff lines, as first-last (1-based, default all)")
+	flag.IntSliceVar(&exportHunks, "export-hunks", nil, "Export only these hunks, numbered from 1 (e.g. 2,3); patches stay applicable")
+	flag.StringVar(&exportSite, "export-site", "", "Write an HTML site with an index and a page per changed file to this directory")
+	flag.BoolVar(&noRedact, "no-redact", false, "Do not mask built-in secret formats (keys, tokens, passwords) in exports")
+	flag.StringArrayVar(&redactPatterns, "redact-pattern", nil, "Regex masked in exports and copies; a capture group limits the mask (can be repeated)")
+	flag.StringVar(&loadPath, "load", "", "View a diff saved with --export-format json or ndjson")
+	flag.BoolVarP(&brief, "brief", "q", false, "Only report whether the files differ")
+	flag.BoolVar(&showStat, "stat", false, "Print a diffstat of the changed files instead of the diff")
+	flag.BoolVar(&showNumstat, "numstat", false, "Print added and removed line counts per changed file, tab-separated")
+	flag.BoolVar(&showShortstat, "shortstat", false, "Print only the summary line of the diffstat")
+	flag.IntVar(&maxAdded, "max-added", -1, "Exit with status 3 when a file has more added lines than this")
+	flag.IntVar(&maxRemoved, "max-removed", -1, "Exit with status 3 when a file has more removed lines than this")
+	flag.Float64Var(&maxChangedPct, "max-changed-percent", -1, "Exit with status 3 when more than this percentage of a file's lines changed")
+	flag.BoolVarP(&reportIdentical, "report-identical-files", "s", false, "Report when the two files are the same")
 	flag.BoolVarP(&help, "help", "h", false, "Show help information")
 	flag.Usage = usage
 }
@@ -56,6 +121,9 @@ func usage() {
 	fmt.Println("Usage:")
 	fmt.Println("  gdiff [options] <file1> <file2>")
 	fmt.Println("  gdiff --ref1 <refA> --ref2 <refB> <tracked file>")
+	fmt.Println("  gdiff --worktree <path> [--ref2 <ref>] <tracked file>")
+	fmt.Println("  gdiff --review [target]")
+	fmt.Println("  gdiff --range-diff <old-range> <new-range> | <base> <old-tip> <new-tip>")
 	fmt.Println("")
 	fmt.Println("Options:")
 	flag.PrintDefaults()
@@ -64,7 +132,23 @@ func usage() {
 	fmt.Println("  gdiff old.txt new.txt")
 	fmt.Println("  gdiff -n old.json new.json          # Hide line numbers")
 	fmt.Println("  gdiff -t 2 config1.yaml config2.yaml # Use 2-space tabs")
+	fmt.Println("  gdiff --ref1 stash@{0} main.go      # What the latest stash changed")
+	fmt.Println("  gdiff --worktree ../feature main.go # Compare against a sibling worktree")
+	fmt.Println("  gdiff --review origin/main          # Review the branch like a pull request")
+	fmt.Println("  gdiff --review --ref2 WORKTREE      # Include uncommitted changes")
+	fmt.Println("  gdiff --range-diff main topic@{1} topic # How a rebase changed each commit")
 	fmt.Println("  gdiff --export-format html --export-file diff.html fileA fileB # Export without TUI")
+	fmt.Println("  gdiff --export-format html --export-layout side-by-side old.txt new.txt > diff.html # Paired columns")
+	fmt.Println("  gdiff --template review old.go new.go > review.html # Uses review.html.tmpl from --template-dir")
+	fmt.Println("  gdiff --export-format svg --export-lines 10-40 -W 100 a.go b.go > diff.svg # Snapshot of the view")
+	fmt.Println("  gdiff --export-site site/ old-dir/ new-dir/ # Index page plus a page per changed file")
+	fmt.Println("  gdiff --export-format json a.go b.go > diff.json && gdiff --load diff.json # View a saved diff")
+	fmt.Println("  gdiff --export-format markdown-html old.go new.go | gh pr comment -F - # Token emphasis in comments")
+	fmt.Println("  gdiff --ref1 HEAD --export-format patch -U 5 main.go | git apply -R # Revert via a patch")
+	fmt.Println("  gdiff --ref1 HEAD --export-format patch --export-hunks 2 main.go | git apply -R # Revert one hunk")
+	fmt.Println("  gdiff --export-format side-by-side -W 100 old.txt new.txt # Like diff -y")
+	fmt.Println("  gdiff -q a.json b.json || echo changed # Exit status 0 same, 1 different, 2 trouble")
+	fmt.Println("  gdiff --stat --max-changed-percent 20 gen-old/ gen-new/ # Status 3 when generated files drift")
 	fmt.Println("")
 	fmt.Println("Keyboard shortcuts:")
 	fmt.Println("  j/↓    Scroll down")
@@ -76,10 +160,16 @@ func usage() {
 	fmt.Println("  v      Toggle side-by-side view")
 	fmt.Println("  c      Toggle syntax highlighting")
 	fmt.Println("  s      Toggle statistics panel")
-	fmt.Println("  b      Toggle blame overlay")
+	fmt.Println("  b      Toggle blame column (enter opens the cursor line's commit)")
 	fmt.Println("  S      Show git status")
 	fmt.Println("  B      Open branch switcher (cycle with [ and ])")
-	fmt.Println("  H      View recent commit history")
+	fmt.Println("  l      Trace the cursor line through history (git log -L)")
+	fmt.Println("  R      Open ref picker (tab switches left/right, accepts HEAD~3 etc.)")
+	fmt.Println("  { / }  Previous/next file (--review) or commit pair (--range-diff)")
+	fmt.Println("  M      Step into the submodule under the cursor file, or back out of it")
+	fmt.Println("  V      Select lines from the cursor; y copies them, esc cancels")
+	fmt.Println("  Y      Copy the hunk under the cursor")
+	fmt.Println("  H      Browse file history (enter: diff vs parent, r: diff vs right ref)")
 	fmt.Println("  ?/h    Toggle help panel")
 	fmt.Println("  q      Quit")
 }
@@ -88,15 +178,297 @@ func parseExportFormat(raw string) (export.Format, error) {
 	switch strings.ToLower(raw) {
 	case "", string(export.FormatMarkdown), "md":
 		return export.FormatMarkdown, nil
+	case string(export.FormatMarkdownHTML), "gfm":
+		return export.FormatMarkdownHTML, nil
 	case string(export.FormatHTML), "htm":
 		return export.FormatHTML, nil
-	case string(export.FormatANSI), "text", "ansi":
+	case string(export.FormatANSI), "text":
 		return export.FormatANSI, nil
+	case string(export.FormatTemplate):
+		return export.FormatTemplate, nil
+	case string(export.FormatSVG):
+		return export.FormatSVG, nil
+	case string(export.FormatJSON):
+		return export.FormatJSON, nil
+	case string(export.FormatNDJSON), "jsonl":
+		return export.FormatNDJSON, nil
+	case string(export.FormatPatch), "diff", "unified":
+		return export.FormatPatch, nil
+	case string(export.FormatNormal):
+		return export.FormatNormal, nil
+	case string(export.FormatContext):
+		return export.FormatContext, nil
+	case string(export.FormatEd):
+		return export.FormatEd, nil
+	case string(export.FormatSideBySide), "columns":
+		return export.FormatSideBySide, nil
 	default:
 		return "", fmt.Errorf("unsupported export format: %s", raw)
 	}
 }
 
+// statsRequested reports whether a diffstat or a change limit replaces the
+// viewer.
+func statsRequested() bool {
+	return showStat || showNumstat || showShortstat || maxAdded >= 0 || maxRemoved >= 0 || maxChangedPct >= 0
+}
+
+// reportStats prints the requested diffstats of files to stdout and each
+// --max-* limit a file breaches to stderr, reporting whether any did.
+func reportStats(files []export.SiteFile) bool {
+	stats := make([]export.FileStat, len(files))
+	for i, file := range files {
+		stats[i] = file.Stat()
+	}
+
+	var err error
+	if showNumstat {
+		err = export.WriteNumstat(os.Stdout, stats)
+	}
+	if err == nil && showStat {
+		err = export.WriteStat(os.Stdout, stats, export.StatWidth)
+	} else if err == nil && showShortstat {
+		err = export.WriteShortstat(os.Stdout, stats)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing stats: %v\n", err)
+		os.Exit(exitTrouble)
+	}
+
+	limits := export.StatLimits{MaxAdded: maxAdded, MaxRemoved: maxRemoved, MaxChangedPercent: maxChangedPct}
+	breached := false
+	for _, stat := range stats {
+		for _, breach := range limits.Breaches(stat) {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", stat.Path, breach)
+			breached = true
+		}
+	}
+	return breached
+}
+
+// finishFiles ends a run over several files, or one file standing in for
+// them: it prints the requested stats, writes the --export-site site and
+// exits with the diff status, or exitLimit when a file breached a limit.
+func finishFiles(cfg *config.Config, redactor *export.Redactor, title string, files []export.SiteFile) {
+	breached := reportStats(files)
+	if exportSite != "" {
+		writeSite(cfg, redactor, title, files)
+	}
+
+	switch {
+	case breached:
+		os.Exit(exitLimit)
+	case slices.ContainsFunc(files, func(file export.SiteFile) bool { return file.Result.HasChanges() }):
+		os.Exit(exitDiffer)
+	default:
+		os.Exit(exitSame)
+	}
+}
+
+// writeSite writes the --export-site site for files.
+func writeSite(cfg *config.Config, redactor *export.Redactor, title string, files []export.SiteFile) {
+	redacted := 0
+	for i := range files {
+		var n int
+		files[i].Result, n = redactor.Result(files[i].Result)
+		redacted += n
+	}
+	warnRedacted(redacted)
+
+	err := export.WriteSite(exportSite, title, files, export.Options{
+		ShowLineNumbers: cfg.ShowLineNo,
+		Context:         cfg.ContextLines,
+		SideBySide:      exportLayout == "side-by-side",
+		Theme:           &cfg.Theme,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing site: %v\n", err)
+		os.Exit(exitTrouble)
+	}
+
+	fmt.Fprintf(os.Stderr, "Wrote %s (%d changed files)\n", filepath.Join(exportSite, "index.html"), len(files))
+}
+
+func warnRedacted(count int) {
+	if count > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: redacted %d secrets from the export\n", count)
+	}
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// diffDirectories diffs every file that differs between two directory trees,
+// including files present on one side only. Version control directories are
+// skipped.
+func diffDirectories(engine *diff.Engine, leftDir, rightDir string) ([]export.SiteFile, error) {
+	paths := map[string]bool{}
+	for _, dir := range []string{leftDir, rightDir} {
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if d.Name() == ".git" {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			paths[filepath.ToSlash(rel)] = true
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sorted := make([]string, 0, len(paths))
+	for path := range paths {
+		sorted = append(sorted, path)
+	}
+	sort.Strings(sorted)
+
+	var files []export.SiteFile
+	for _, rel := range sorted {
+		leftPath, rightPath := filepath.Join(leftDir, rel), filepath.Join(rightDir, rel)
+		left, leftErr := readOptional(leftPath)
+		right, rightErr := readOptional(rightPath)
+		if leftErr != nil {
+			return nil, leftErr
+		}
+		if rightErr != nil {
+			return nil, rightErr
+		}
+		if left != nil && right != nil && bytes.Equal(left, right) {
+			continue
+		}
+
+		status := "modified"
+		switch {
+		case left == nil:
+			status, leftPath = "added", ""
+		case right == nil:
+			status, rightPath = "deleted", ""
+		}
+		files = append(files, export.SiteFile{Path: rel, Status: status, Result: diffContents(engine, leftPath, left, rightPath, right)})
+	}
+	return files, nil
+}
+
+// readOptional reads a file, returning nil without an error when it is
+// missing.
+func readOptional(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// diffContents diffs two file contents, summarising binary ones the way
+// tui.DiffSides does. A missing side is labelled /dev/null.
+func diffContents(engine *diff.Engine, leftPath string, left []byte, rightPath string, right []byte) *diff.DiffResult {
+	leftLabel, rightLabel := leftPath, rightPath
+	if leftLabel == "" {
+		leftLabel = export.DevNull
+	}
+	if rightLabel == "" {
+		rightLabel = export.DevNull
+	}
+	if git.IsBinary(left) || git.IsBinary(right) {
+		result := engine.DiffLines(tui.BinaryLines(leftPath, left), tui.BinaryLines(rightPath, right), leftLabel, rightLabel)
+		result.Binary = true
+		return result
+	}
+
+	result := engine.DiffLines(git.SplitLines(left), git.SplitLines(right), leftLabel, rightLabel)
+	result.File1NoEOL = diff.MissingFinalNewline(left)
+	result.File2NoEOL = diff.MissingFinalNewline(right)
+	result.FuncName = diff.DefaultFuncName
+	return result
+}
+
+// diffReviewFiles diffs each of gitCtx.Files between Ref1 and Ref2, as the
+// viewer does when stepping through them.
+func diffReviewFiles(ctx context.Context, engine *diff.Engine, gitCtx tui.GitContext) ([]export.SiteFile, error) {
+	var files []export.SiteFile
+	for _, rel := range gitCtx.Files {
+		leftPath, rightPath, err := tui.ResolvePaths(ctx, gitCtx.RepoRoot, rel, gitCtx.Ref1, gitCtx.Ref2)
+		if err != nil {
+			return nil, err
+		}
+		result, err := tui.DiffSides(ctx, engine, gitCtx.RepoRoot, rel, gitCtx.Ref1, leftPath, gitCtx.Ref2, rightPath)
+		if err != nil {
+			return nil, err
+		}
+
+		file := export.SiteFile{Path: rel, Status: "modified", Result: result}
+		switch {
+		case leftPath == "":
+			file.Status = "added"
+		case rightPath == "":
+			file.Status = "deleted"
+		case leftPath != rightPath:
+			file.Status, file.Path, file.OldPath = "renamed", rightPath, leftPath
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// parseLineRange reads a 1-based first-last range, where either end may be
+// left out, into 0-based start and end indexes. An end of 0 means the last
+// line.
+func parseLineRange(raw string) (int, int, error) {
+	if raw == "" {
+		return 0, 0, nil
+	}
+	first, last, found := strings.Cut(raw, "-")
+	if !found {
+		last = first
+	}
+
+	start, end := 0, 0
+	var err error
+	if first != "" {
+		if start, err = strconv.Atoi(first); err != nil || start < 1 {
+			return 0, 0, fmt.Errorf("invalid line range %q", raw)
+		}
+		start--
+	}
+	if last != "" {
+		if end, err = strconv.Atoi(last); err != nil || end <= start {
+			return 0, 0, fmt.Errorf("invalid line range %q", raw)
+		}
+	}
+	return start, end, nil
+}
+
+// hunkSpan returns the 0-based lines from the first to the last of the
+// selected hunks and line ranges, which an svg export draws as one view.
+func hunkSpan(result *diff.DiffResult, hunks []int, context int, lines []export.LineRange) (int, int) {
+	start, end := len(result.Lines), 0
+	all := result.Hunks(context)
+	for _, h := range hunks {
+		if h >= 0 && h < len(all) {
+			start, end = min(start, all[h].Start), max(end, all[h].End)
+		}
+	}
+	for _, r := range lines {
+		start, end = min(start, r.Start), max(end, r.End)
+	}
+	if start >= end {
+		return 0, 0
+	}
+	return start, end
+}
+
 func buildExportTitle(result *diff.DiffResult) string {
 	if result == nil {
 		return ""
@@ -104,7 +476,7 @@ func buildExportTitle(result *diff.DiffResult) string {
 	return fmt.Sprintf("%s ↔ %s", filepath.Base(result.File1Name), filepath.Base(result.File2Name))
 }
 
-func loadGitDiff(engine *diff.Engine, target, leftRef, rightRef string, includeBlame bool) (tui.GitContext, *diff.DiffResult, error) {
+func loadGitDiff(ctx context.Context, engine *diff.Engine, target, leftRef, rightRef string, includeBlame bool) (tui.GitContext, *diff.DiffResult, error) {
 	repoRoot, err := findRepoRoot(target)
 	if err != nil {
 		// Degrade gracefully if not a repository
@@ -125,121 +497,231 @@ func loadGitDiff(engine *diff.Engine, target, leftRef, rightRef string, includeB
 		leftRef = "HEAD"
 	}
 	if rightRef == "" {
-		rightRef = "WORKTREE"
+		rightRef = git.Worktree
+	}
+	for _, ref := range []string{leftRef, rightRef} {
+		if err := checkLinkedWorktree(ctx, repoRoot, ref); err != nil {
+			return tui.GitContext{}, nil, err
+		}
 	}
 
-	lines1, err := readLinesFromGit(repoRoot, relPath, leftRef)
+	// Follow renames so each side reads the path the file had at that ref.
+	leftPath, rightPath, err := tui.ResolvePaths(ctx, repoRoot, relPath, leftRef, rightRef)
 	if err != nil {
 		return tui.GitContext{}, nil, err
 	}
-	lines2, err := readLinesFromGit(repoRoot, relPath, rightRef)
+	if leftPath == "" && rightPath == "" {
+		return tui.GitContext{}, nil, fmt.Errorf("%s does not exist at %s or %s", relPath, leftRef, rightRef)
+	}
+
+	diffResult, err := tui.DiffSides(ctx, engine, repoRoot, relPath, leftRef, leftPath, rightRef, rightPath)
 	if err != nil {
 		return tui.GitContext{}, nil, err
 	}
 
-	leftLabel := fmt.Sprintf("%s:%s", leftRef, relPath)
-	rightLabel := fmt.Sprintf("%s:%s", rightRef, relPath)
-
-	diffResult := engine.DiffLines(lines1, lines2, leftLabel, rightLabel)
-
 	gitCtx := tui.GitContext{
-		RepoRoot: repoRoot,
-		FilePath: relPath,
-		Ref1:     leftRef,
-		Ref2:     rightRef,
-		Enabled:  true,
+		RepoRoot:  repoRoot,
+		FilePath:  relPath,
+		LeftPath:  leftPath,
+		RightPath: rightPath,
+		Ref1:      leftRef,
+		Ref2:      rightRef,
+		Enabled:   true,
 	}
 
-	gitCtx.Status, _ = gitCommandLines(repoRoot, "status", "--short")
-	gitCtx.Branches, _ = gitCommandLines(repoRoot, "branch", "--format", "%(refname:short)")
-	gitCtx.CurrentBranch, _ = gitCurrentBranch(repoRoot)
-	gitCtx.CommitHistory, _ = gitCommandLines(repoRoot, "log", "--oneline", "-n", "20")
+	gitCtx.Status, _ = tui.LoadStatus(ctx, repoRoot)
+	gitCtx.Branches, _ = git.Open(repoRoot).Lines(ctx, "branch", "--format", "%(refname:short)")
+	gitCtx.CurrentBranch, _ = gitCurrentBranch(ctx, repoRoot)
+	gitCtx.HistoryRef = rightRef
+	gitCtx.CommitHistory, _ = tui.LoadFileHistory(ctx, repoRoot, relPath, rightRef, 0, tui.HistoryPageSize)
 
 	if includeBlame {
-		gitCtx.Blame, _ = gitBlame(repoRoot, relPath, rightRef)
+		gitCtx.Blame, _ = tui.LoadBlame(ctx, repoRoot, rightPath, rightRef)
+		gitCtx.BlameLeft, _ = tui.LoadBlame(ctx, repoRoot, leftPath, leftRef)
 		gitCtx.ShowBlame = true
 	}
 
 	return gitCtx, diffResult, nil
 }
 
-func findRepoRoot(path string) (string, error) {
-	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
-	cmd.Dir = filepath.Dir(path)
-	out, err := cmd.Output()
+// loadReview diffs every file changed between the merge-base of target and
+// HEAD and rightRef, which defaults to HEAD. This matches the three-dot
+// semantics code hosts use for pull requests.
+func loadReview(ctx context.Context, engine *diff.Engine, target, rightRef string, includeBlame bool) (tui.GitContext, *diff.DiffResult, error) {
+	repoRoot, err := findRepoRoot(".")
 	if err != nil {
-		return "", err
+		return tui.GitContext{}, nil, fmt.Errorf("git repository not detected: %w", err)
 	}
-	return strings.TrimSpace(string(out)), nil
-}
 
-func readLinesFromGit(repoRoot, relPath, ref string) ([]string, error) {
-	if ref == "" || ref == "WORKTREE" {
-		fullPath := filepath.Join(repoRoot, relPath)
-		data, err := os.ReadFile(fullPath)
-		if err != nil {
-			return nil, err
+	if target == "" {
+		target = defaultReviewTarget(ctx, repoRoot)
+		if target == "" {
+			return tui.GitContext{}, nil, fmt.Errorf("no upstream, main or master branch to review against")
 		}
-		return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n"), nil
+	}
+	if rightRef == "" {
+		rightRef = "HEAD"
 	}
 
-	cmd := exec.Command("git", "-C", repoRoot, "show", fmt.Sprintf("%s:%s", ref, relPath))
-	out, err := cmd.Output()
+	base, err := git.Open(repoRoot).Lines(ctx, "merge-base", target, "HEAD")
+	if err != nil || len(base) == 0 {
+		return tui.GitContext{}, nil, fmt.Errorf("no merge-base between %s and HEAD", target)
+	}
+	baseRef := base[0]
+
+	args := []string{"diff", "--name-only", "-z", "-M", baseRef}
+	if !git.IsWorktree(rightRef) {
+		args = append(args, rightRef)
+	}
+	out, err := git.Open(repoRoot).Run(ctx, args...)
 	if err != nil {
-		return nil, err
+		return tui.GitContext{}, nil, err
+	}
+
+	var files []string
+	for _, name := range strings.Split(string(out), "\x00") {
+		if name != "" {
+			files = append(files, name)
+		}
 	}
-	text := strings.TrimSuffix(string(out), "\n")
-	if text == "" {
-		return []string{}, nil
+	if len(files) == 0 {
+		return tui.GitContext{}, nil, fmt.Errorf("no changes between %s and %s", target, rightRef)
 	}
-	return strings.Split(text, "\n"), nil
+
+	gitCtx, diffResult, err := loadGitDiff(ctx, engine, filepath.Join(repoRoot, files[0]), baseRef, rightRef, includeBlame)
+	if err != nil {
+		return tui.GitContext{}, nil, err
+	}
+
+	gitCtx.ReviewBase = baseRef
+	gitCtx.ReviewTarget = target
+	gitCtx.Files = files
+	gitCtx.HistoryRef = baseRef + "..HEAD"
+	gitCtx.CommitHistory, _ = tui.LoadFileHistory(ctx, repoRoot, "", gitCtx.HistoryRef, 0, tui.HistoryPageSize)
+
+	return gitCtx, diffResult, nil
 }
 
-func gitCommandLines(repoRoot string, args ...string) ([]string, error) {
-	cmd := exec.Command("git", append([]string{"-C", repoRoot}, args...)...)
-	out, err := cmd.Output()
+// loadRangeDiff pairs the commits of two ranges, given either as two ranges
+// or as a base and two tips, and diffs the patch text of the first pair that
+// changed.
+func loadRangeDiff(ctx context.Context, engine *diff.Engine, args []string) (tui.GitContext, *diff.DiffResult, error) {
+	var oldRange, newRange string
+	switch len(args) {
+	case 2:
+		oldRange, newRange = args[0], args[1]
+	case 3:
+		oldRange, newRange = args[0]+".."+args[1], args[0]+".."+args[2]
+	default:
+		return tui.GitContext{}, nil, fmt.Errorf("expected <old-range> <new-range> or <base> <old-tip> <new-tip>")
+	}
+
+	repoRoot, err := findRepoRoot(".")
 	if err != nil {
-		return nil, err
+		return tui.GitContext{}, nil, fmt.Errorf("git repository not detected: %w", err)
 	}
-	text := strings.TrimSpace(string(out))
-	if text == "" {
-		return []string{}, nil
+
+	pairs, err := tui.LoadRangeDiff(ctx, repoRoot, oldRange, newRange)
+	if err != nil {
+		return tui.GitContext{}, nil, err
+	}
+	if len(pairs) == 0 {
+		return tui.GitContext{}, nil, fmt.Errorf("no commits in %s or %s", oldRange, newRange)
+	}
+
+	index := 0
+	for i, pair := range pairs {
+		if pair.Marker() != "=" {
+			index = i
+			break
+		}
+	}
+
+	gitCtx := tui.GitContext{
+		Enabled:    true,
+		RepoRoot:   repoRoot,
+		Ref1:       oldRange,
+		Ref2:       newRange,
+		RangePairs: pairs,
+		PairIndex:  index,
+		HistoryRef: newRange,
 	}
-	return strings.Split(text, "\n"), nil
+	gitCtx.Status, _ = tui.LoadStatus(ctx, repoRoot)
+	gitCtx.Branches, _ = git.Open(repoRoot).Lines(ctx, "branch", "--format", "%(refname:short)")
+	gitCtx.CurrentBranch, _ = gitCurrentBranch(ctx, repoRoot)
+	gitCtx.CommitHistory, _ = tui.LoadFileHistory(ctx, repoRoot, "", newRange, 0, tui.HistoryPageSize)
+
+	pair := pairs[index]
+	return gitCtx, engine.DiffLines(pair.OldPatch, pair.NewPatch, pair.OldLabel(), pair.NewLabel()), nil
 }
 
-func gitCurrentBranch(repoRoot string) (string, error) {
-	branches, err := gitCommandLines(repoRoot, "branch", "--show-current")
-	if err != nil {
-		return "", err
+// defaultReviewTarget returns the upstream tracking branch, falling back to
+// main or master.
+func defaultReviewTarget(ctx context.Context, repoRoot string) string {
+	if upstream, err := git.Open(repoRoot).Lines(ctx, "rev-parse", "--abbrev-ref", "--symbolic-full-name", "@{u}"); err == nil && len(upstream) > 0 {
+		return upstream[0]
 	}
-	if len(branches) == 0 {
-		return "", nil
+	for _, branch := range []string{"main", "master"} {
+		if _, err := git.Open(repoRoot).Lines(ctx, "rev-parse", "--verify", "--quiet", branch); err == nil {
+			return branch
+		}
 	}
-	return branches[0], nil
+	return ""
 }
 
-func gitBlame(repoRoot, relPath, ref string) (map[int]string, error) {
-	blame := make(map[int]string)
+func loadJSONDiff(path string) (*diff.DiffResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return export.ReadJSON(f)
+}
 
-	target := relPath
-	if ref != "" && ref != "WORKTREE" {
-		target = fmt.Sprintf("%s:%s", ref, relPath)
+func findRepoRoot(path string) (string, error) {
+	// The target may be deleted in the working tree, so start from the
+	// closest directory that still exists.
+	dir := filepath.Dir(path)
+	for {
+		if _, err := os.Stat(dir); err == nil || filepath.Dir(dir) == dir {
+			break
+		}
+		dir = filepath.Dir(dir)
 	}
 
-	args := []string{"-C", repoRoot, "blame", "-l", target}
-	cmd := exec.Command("git", args...)
-	out, err := cmd.Output()
-	if err != nil {
-		return blame, err
+	return git.TopLevel(dir)
+}
+
+// checkLinkedWorktree verifies that a worktree:<dir> ref names a worktree of
+// the repository rather than an arbitrary directory.
+func checkLinkedWorktree(ctx context.Context, repoRoot, ref string) error {
+	dir, ok := git.LinkedWorktree(ref)
+	if !ok {
+		return nil
 	}
 
-	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
-	for idx, line := range lines {
-		blame[idx+1] = strings.TrimSpace(line)
+	worktrees, err := tui.LoadLinkedWorktrees(ctx, repoRoot)
+	if err != nil {
+		return fmt.Errorf("unable to list worktrees: %w", err)
+	}
+	want, _ := filepath.EvalSymlinks(dir)
+	for _, wt := range worktrees {
+		if resolved, _ := filepath.EvalSymlinks(wt.Path); resolved == want {
+			return nil
+		}
 	}
+	return fmt.Errorf("%s is not a linked worktree of %s", dir, repoRoot)
+}
 
-	return blame, nil
+func gitCurrentBranch(ctx context.Context, repoRoot string) (string, error) {
+	branches, err := git.Open(repoRoot).Lines(ctx, "branch", "--show-current")
+	if err != nil {
+		return "", err
+	}
+	if len(branches) == 0 {
+		return "", nil
+	}
+	return branches[0], nil
 }
 
 func main() {
@@ -262,10 +744,20 @@ func main() {
 	cfg := config.DefaultConfig()
 	cfg.ShowLineNo = !noLineNumber
 	cfg.TabSize = tabSize
+	cfg.ContextLines = contextLines
 	cfg.IgnoreWhitespace = ignoreWhitespace
 	cfg.IgnorePatterns = ignorePatterns
 	cfg.Language = language
 	cfg.TokenPatterns = tokenPatterns
+	cfg.BlameHeatmap = blameHeatmap
+	cfg.TemplateDir = templateDir
+	cfg.RedactSecrets = !noRedact
+	cfg.RedactPatterns = redactPatterns
+	redactor, redactErr := export.NewRedactor(cfg.RedactPatterns, cfg.RedactSecrets)
+	if redactErr != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", redactErr)
+		os.Exit(exitTrouble)
+	}
 
 	engine := diff.NewEngine(diff.EngineOptions{
 		Language:         cfg.Language,
@@ -274,7 +766,21 @@ func main() {
 		TokenPatterns:    cfg.TokenPatterns,
 	})
 
+	if worktreePath != "" {
+		if ref1 != "" {
+			fmt.Fprintln(os.Stderr, "Error: --worktree and --ref1 both set the left side")
+			os.Exit(exitTrouble)
+		}
+		abs, err := filepath.Abs(worktreePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitTrouble)
+		}
+		ref1 = git.LinkedWorktreePrefix + abs
+	}
+
 	gitDiffMode := ref1 != "" || ref2 != ""
+	ctx := context.Background()
 
 	var (
 		diffResult *diff.DiffResult
@@ -282,103 +788,301 @@ func main() {
 		gitCtx     tui.GitContext
 	)
 
-	if gitDiffMode {
+	if review {
+		target := ""
+		if len(args) > 0 {
+			target = args[0]
+		}
+		gitCtx, diffResult, err = loadReview(ctx, engine, target, ref2, showBlame)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error preparing review: %v\n", err)
+			os.Exit(exitTrouble)
+		}
+	} else if rangeDiff {
+		gitCtx, diffResult, err = loadRangeDiff(ctx, engine, args)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error preparing range-diff: %v\n", err)
+			os.Exit(exitTrouble)
+		}
+	} else if loadPath != "" {
+		diffResult, err = loadJSONDiff(loadPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading %s: %v\n", loadPath, err)
+			os.Exit(exitTrouble)
+		}
+	} else if gitDiffMode {
 		if len(args) < 1 {
 			usage()
-			os.Exit(1)
+			os.Exit(exitTrouble)
 		}
 
 		target := args[0]
-		gitCtx, diffResult, err = loadGitDiff(engine, target, ref1, ref2, showBlame)
+		gitCtx, diffResult, err = loadGitDiff(ctx, engine, target, ref1, ref2, showBlame)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error preparing git diff: %v\n", err)
-			os.Exit(1)
+			os.Exit(exitTrouble)
 		}
 	} else {
 		if len(args) < 2 {
 			usage()
-			os.Exit(1)
+			os.Exit(exitTrouble)
 		}
 
 		file1 := args[0]
 		file2 := args[1]
 
+		if isDir(file1) && isDir(file2) {
+			if exportSite == "" && !statsRequested() {
+				fmt.Fprintln(os.Stderr, "Error: comparing directories needs --export-site or --stat, --numstat, --shortstat or a --max-* limit")
+				os.Exit(exitTrouble)
+			}
+			files, err := diffDirectories(engine, file1, file2)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error comparing directories: %v\n", err)
+				os.Exit(exitTrouble)
+			}
+			finishFiles(cfg, redactor, fmt.Sprintf("%s ↔ %s", file1, file2), files)
+		}
+
 		// Check if files exist
 		if _, err := os.Stat(file1); os.IsNotExist(err) {
 			fmt.Fprintf(os.Stderr, "Error: file '%s' does not exist\n", file1)
-			os.Exit(1)
+			os.Exit(exitTrouble)
 		}
 		if _, err := os.Stat(file2); os.IsNotExist(err) {
 			fmt.Fprintf(os.Stderr, "Error: file '%s' does not exist\n", file2)
-			os.Exit(1)
+			os.Exit(exitTrouble)
 		}
 
 		diffResult, err = engine.DiffFiles(file1, file2)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error computing diff: %v\n", err)
-			os.Exit(1)
+			os.Exit(exitTrouble)
 		}
 	}
 
-	// Initialize configuration
-	cfg := config.DefaultConfig()
-	cfg.ShowLineNo = !noLineNumber
-	cfg.TabSize = tabSize
-	cfg.IgnoreWhitespace = ignoreWhitespace
+	status := exitSame
+	if diffResult.HasChanges() {
+		status = exitDiffer
+	}
 
-	if exportFormat != "" || exportFile != "" || exportCopy {
+	if exportSite != "" || statsRequested() {
+		files := []export.SiteFile{{Path: diffResult.File2Name, Status: "modified", Result: diffResult}}
+		if len(gitCtx.Files) > 0 {
+			files, err = diffReviewFiles(ctx, engine, gitCtx)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(exitTrouble)
+			}
+		} else if gitCtx.Enabled {
+			files[0].Path = gitCtx.FilePath
+		} else if diffResult.File1Name != diffResult.File2Name && diffResult.HasChanges() {
+			// Like git diff --no-index, two files with the same content are
+			// not listed as a rename.
+			files[0].OldPath = diffResult.File1Name
+		}
+		finishFiles(cfg, redactor, buildExportTitle(diffResult), files)
+	}
+
+	if brief {
+		if status == exitDiffer {
+			fmt.Printf("Files %s and %s differ\n", diffResult.File1Name, diffResult.File2Name)
+		} else if reportIdentical {
+			printIdentical(diffResult)
+		}
+		os.Exit(status)
+	}
+
+	if exportFormat != "" || exportFile != "" || exportCopy || templateName != "" {
 		format, err := parseExportFormat(exportFormat)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+			os.Exit(exitTrouble)
+		}
+		if templateName != "" && exportFormat == "" {
+			format = export.FormatTemplate
 		}
 		if format == "" {
 			format = export.FormatMarkdown
 		}
+		var templatePath string
+		if format == export.FormatTemplate {
+			if templateName == "" {
+				fmt.Fprintln(os.Stderr, "Error: the template format needs --template")
+				os.Exit(exitTrouble)
+			}
+			tmpl, err := export.FindTemplate(cfg.TemplateDir, templateName)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(exitTrouble)
+			}
+			templatePath = tmpl.Path
+		}
+		if exportLayout != "unified" && exportLayout != "side-by-side" {
+			fmt.Fprintf(os.Stderr, "Error: unsupported export layout %q (use unified or side-by-side)\n", exportLayout)
+			os.Exit(exitTrouble)
+		}
+		colors, err := export.ParseColorDepth(exportColors)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitTrouble)
+		}
+
+		exported, redacted, err := redactor.ResultFor(diffResult, format)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitTrouble)
+		}
+		warnRedacted(redacted)
+
+		first, last, err := parseLineRange(exportLines)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitTrouble)
+		}
+		var lines []export.LineRange
+		if exportLines != "" {
+			if last == 0 {
+				last = len(exported.Lines)
+			}
+			lines = []export.LineRange{{Start: first, End: last}}
+		}
+		var hunks []int
+		for _, h := range exportHunks {
+			hunks = append(hunks, h-1)
+		}
 
-		rendered, err := export.Render(diffResult, format, export.Options{
+		var view []string
+		if format == export.FormatSVG {
+			if len(hunks) > 0 {
+				first, last = hunkSpan(exported, hunks, cfg.ContextLines, lines)
+			}
+			view = tui.RenderSnapshot(exported, cfg, engine, gitCtx, tui.SnapshotOptions{
+				Start:      first,
+				End:        last,
+				Width:      textWidth,
+				SideBySide: exportLayout == "side-by-side",
+			})
+		}
+
+		oldPath, newPath := gitCtx.PatchPaths()
+		opts := export.Options{
 			Title:           buildExportTitle(diffResult),
 			ShowLineNumbers: cfg.ShowLineNo,
-		})
+			Context:         cfg.ContextLines,
+			OldPath:         oldPath,
+			NewPath:         newPath,
+			Width:           textWidth,
+			SideBySide:      exportLayout == "side-by-side",
+			Theme:           &cfg.Theme,
+			Colors:          colors,
+			Template:        templatePath,
+			View:            view,
+			Lines:           lines,
+			Hunks:           hunks,
+		}
+
+		// ndjson is written a record at a time unless it has to be held
+		// for the clipboard.
+		var rendered string
+		streamed := format == export.FormatNDJSON && !exportCopy
+		if streamed {
+			err = writeNDJSON(exportFile, exported, opts)
+		} else {
+			rendered, err = export.Render(exported, format, opts)
+		}
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error exporting diff: %v\n", err)
-			os.Exit(1)
+			os.Exit(exitTrouble)
 		}
 
 		if exportFile != "" {
-			if err := os.WriteFile(exportFile, []byte(rendered), 0o644); err != nil {
-				fmt.Fprintf(os.Stderr, "Error writing export: %v\n", err)
-				os.Exit(1)
+			if !streamed {
+				if err := os.WriteFile(exportFile, []byte(rendered), 0o644); err != nil {
+					fmt.Fprintf(os.Stderr, "Error writing export: %v\n", err)
+					os.Exit(exitTrouble)
+				}
 			}
 			fmt.Fprintf(os.Stdout, "Diff saved to %s\n", exportFile)
 		}
 
 		if exportCopy {
-			if err := export.CopyToClipboard(rendered, os.Stdout); err != nil {
+			copied, err := export.CopyToClipboard(rendered, os.Stdout)
+			if err != nil {
 				fmt.Fprintf(os.Stderr, "Error copying diff to clipboard: %v\n", err)
-				os.Exit(1)
+				os.Exit(exitTrouble)
+			}
+			if copied.Confirmed {
+				fmt.Printf("Diff copied to clipboard via %s.\n", copied.Backend)
+			} else {
+				fmt.Printf("Diff sent to the terminal clipboard via %s; the terminal does not confirm it.\n", copied.Backend)
 			}
-			fmt.Println("Diff copied to clipboard.")
 		}
 
-		if exportFile == "" && !exportCopy {
-			fmt.Println(rendered)
+		if exportFile == "" && !exportCopy && !streamed {
+			// Patches and scripts must reach stdout byte for byte.
+			if rendered == "" || strings.HasSuffix(rendered, "\n") {
+				fmt.Print(rendered)
+			} else {
+				fmt.Println(rendered)
+			}
 		}
-		os.Exit(0)
+		if status == exitSame && reportIdentical {
+			printIdentical(diffResult)
+		}
+		os.Exit(status)
 	}
 
-	// If no changes, just report and exit
-	if !diffResult.HasChanges() {
-		fmt.Println("Files are identical - no differences found.")
-		os.Exit(0)
+	// If no changes, just report and exit. The message stays off stdout
+	// unless asked for, as with diff(1).
+	if status == exitSame {
+		if reportIdentical {
+			printIdentical(diffResult)
+		} else {
+			fmt.Fprintln(os.Stderr, "Files are identical - no differences found.")
+		}
+		os.Exit(status)
 	}
 
 	// Create and run the TUI
 	model := tui.NewModel(diffResult, cfg, engine, gitCtx)
 	p := tea.NewProgram(model, tea.WithAltScreen(), tea.WithMouseCellMotion())
 
-	if _, err := p.Run(); err != nil {
+	_, err = p.Run()
+	git.CloseAll()
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error running TUI: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitTrouble)
+	}
+	os.Exit(status)
+}
+
+// writeNDJSON streams the ndjson export to path, or to stdout when path is
+// empty.
+func writeNDJSON(path string, result *diff.DiffResult, opts export.Options) error {
+	out := os.Stdout
+	if path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	w := bufio.NewWriter(out)
+	if err := export.WriteNDJSON(w, result, opts); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
 	}
+	if out != os.Stdout {
+		return out.Close()
+	}
+	return nil
+}
+
+func printIdentical(result *diff.DiffResult) {
+	fmt.Printf("Files %s and %s are identical\n", result.File1Name, result.File2Name)
 }
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestMain runs gdiff itself instead of the tests when runGdiff re-executes
+// the test binary, so exit statuses can be checked.
+func TestMain(m *testing.M) {
+	if os.Getenv("GDIFF_TEST_MAIN") == "1" {
+		main()
+		return
+	}
+	os.Exit(m.Run())
+}
+
+// runGdiff runs gdiff with args in dir and returns its stdout, stderr and
+// exit status.
+func runGdiff(t *testing.T, dir string, args ...string) (string, string, int) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GDIFF_TEST_MAIN=1", "HOME="+dir, "XDG_CONFIG_HOME="+dir)
+	var stdout, stderr strings.Builder
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+
+	err := cmd.Run()
+	var exit *exec.ExitError
+	switch {
+	case err == nil:
+		return stdout.String(), stderr.String(), 0
+	case errors.As(err, &exit):
+		return stdout.String(), stderr.String(), exit.ExitCode()
+	default:
+		t.Fatal(err)
+		return "", "", 0
+	}
+}
+
+func TestExitStatus(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{"a.txt": "one\ntwo\n", "same.txt": "one\ntwo\n", "b.txt": "one\n2\n"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		args   []string
+		status int
+		stdout string
+		stderr string // a part of stderr
+		usage  bool   // stdout holds the usage instead
+	}{
+		{name: "identical", args: []string{"a.txt", "same.txt"}, status: exitSame, stderr: "Files are identical"},
+		{name: "identical reported", args: []string{"-s", "a.txt", "same.txt"}, status: exitSame,
+			stdout: "Files a.txt and same.txt are identical\n"},
+		{name: "different export", args: []string{"--export-format", "patch", "a.txt", "b.txt"}, status: exitDiffer,
+			stdout: "--- a/a.txt\n+++ b/b.txt\n@@ -1,2 +1,2 @@\n one\n-two\n+2\n"},
+		{name: "brief and different", args: []string{"--brief", "a.txt", "b.txt"}, status: exitDiffer,
+			stdout: "Files a.txt and b.txt differ\n"},
+		{name: "brief and identical", args: []string{"-q", "a.txt", "same.txt"}, status: exitSame},
+		{name: "brief and identical reported", args: []string{"-q", "-s", "a.txt", "same.txt"}, status: exitSame,
+			stdout: "Files a.txt and same.txt are identical\n"},
+		{name: "missing file", args: []string{"--brief", "a.txt", "missing.txt"}, status: exitTrouble,
+			stderr: "file 'missing.txt' does not exist"},
+		{name: "one file", args: []string{"a.txt"}, status: exitTrouble, usage: true},
+		{name: "unknown flag", args: []string{"--no-such-flag", "a.txt", "b.txt"}, status: exitTrouble,
+			stderr: "unknown flag", usage: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout, stderr, status := runGdiff(t, dir, tt.args...)
+			if status != tt.status {
+				t.Errorf("exit status = %d, want %d; stderr: %s", status, tt.status, stderr)
+			}
+			switch {
+			case tt.usage && !strings.Contains(stdout, "Usage:"):
+				t.Errorf("stdout = %q, want the usage", stdout)
+			case !tt.usage && stdout != tt.stdout:
+				t.Errorf("stdout = %q, want %q", stdout, tt.stdout)
+			}
+			if !strings.Contains(stderr, tt.stderr) {
+				t.Errorf("stderr = %q, want it to contain %q", stderr, tt.stderr)
+			}
+		})
+	}
+}